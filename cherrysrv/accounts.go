@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Accounts are stored in a text file, one account per line:
+//
+//	@username:$2a$10$...bcrypt hash...
+//
+// so lines can be created by hand with bin/create_passwd:
+//
+//	echo "@roger:`bin/create_passwd secret`" >> accounts.db
+
+const MIN_PASSWORD_LEN = 4
+
+type AccountStore struct {
+	path         string            // file storing the accounts. Empty means registration is disabled.
+	hashes       map[string]string // @username -> bcrypt hash
+	sync.RWMutex                   // for reading/updating the hashes
+}
+
+func newAccountStore(path string) *AccountStore {
+	return &AccountStore{
+		path:    path,
+		hashes:  make(map[string]string),
+		RWMutex: sync.RWMutex{},
+	}
+}
+
+// load the accounts from disk. A missing file is an empty store.
+func (store *AccountStore) Load() error {
+
+	if no(store.path) {
+		return nil
+	}
+
+	file, err := os.Open(store.path)
+
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	hashes := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+
+	for lineno := 1; scanner.Scan(); lineno++ {
+		line := trim(scanner.Text())
+
+		if no(line) || line[0] == ';' {
+			continue
+		}
+
+		username, hash := split2(line, ":")
+
+		if _, err := ValidUsername(username); err != nil || no(hash) {
+			WARN.Printf("%s:%d ignoring account line (%s)", store.path, lineno, username)
+			continue
+		}
+
+		hashes[username] = hash
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	store.Lock()
+	store.hashes = hashes
+	store.Unlock()
+
+	return nil
+}
+
+// write all the accounts to disk, replacing the file atomically
+func (store *AccountStore) save() error {
+
+	var names []string
+
+	for username := range store.hashes {
+		names = append(names, username)
+	}
+
+	sort.Strings(names)
+
+	var output strings.Builder
+
+	for _, username := range names {
+		output.WriteString(username + ":" + store.hashes[username] + "\n")
+	}
+
+	tmpfile := store.path + ".tmp"
+
+	if err := os.WriteFile(tmpfile, []byte(output.String()), 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpfile, store.path)
+}
+
+// can users register new accounts?
+func (store *AccountStore) Enabled() bool {
+	return !no(store.path)
+}
+
+// is username a registered account?
+func (store *AccountStore) Exists(username string) bool {
+	store.RLock()
+	defer store.RUnlock()
+
+	_, ok := store.hashes[username]
+
+	return ok
+}
+
+// check password against the stored hash of username
+func (store *AccountStore) Check(username string, password string) bool {
+	store.RLock()
+	hash, ok := store.hashes[username]
+	store.RUnlock()
+
+	if !ok {
+		return false
+	}
+
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// create a new account for username and save it to disk
+func (store *AccountStore) Register(username string, password string) error {
+
+	if !store.Enabled() {
+		return fmt.Errorf("registration is disabled in this server")
+	}
+
+	if len(password) < MIN_PASSWORD_LEN {
+		return fmt.Errorf("password must be at least %d chars", MIN_PASSWORD_LEN)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+
+	if err != nil {
+		return err
+	}
+
+	store.Lock()
+	defer store.Unlock()
+
+	if _, ok := store.hashes[username]; ok {
+		return fmt.Errorf("%s is already registered", username)
+	}
+
+	store.hashes[username] = string(hash)
+
+	if err := store.save(); err != nil {
+		delete(store.hashes, username)
+		ERROR.Printf("unable to save accounts to %s (%s)", store.path, err)
+
+		return fmt.Errorf("unable to save the account, try again later")
+	}
+
+	return nil
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAccountStore(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "accounts.db")
+
+	// a hash made by bin/create_passwd for "secret"
+	handmade := "; created by hand\n@roger:$2a$10$uuyjS0.05mAgEvE/3xH51uVUGispyXer5cFdHDCnusjo9rb4W1urC\nnotanaccount\n"
+
+	if err := os.WriteFile(path, []byte(handmade), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	store := newAccountStore(path)
+
+	if err := store.Load(); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if !store.Exists("@roger") {
+		t.Errorf("Exists(@roger) = false, want true")
+	}
+
+	if !store.Check("@roger", "secret") {
+		t.Errorf("Check(@roger, secret) = false, want true")
+	}
+
+	if store.Check("@roger", "notsecret") {
+		t.Errorf("Check(@roger, notsecret) = true, want false")
+	}
+
+	if err := store.Register("@roger", "another"); err == nil {
+		t.Errorf("Register(@roger) of an existing account should fail")
+	}
+
+	if err := store.Register("@john", "abc"); err == nil {
+		t.Errorf("Register(@john) with a short password should fail")
+	}
+
+	if err := store.Register("@john", "cash1234"); err != nil {
+		t.Fatalf("Register(@john) error = %v", err)
+	}
+
+	// a new store must see both the handmade and the registered accounts
+
+	reloaded := newAccountStore(path)
+
+	if err := reloaded.Load(); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if !reloaded.Check("@john", "cash1234") || !reloaded.Check("@roger", "secret") {
+		t.Errorf("reloaded store lost accounts")
+	}
+
+	if err := newAccountStore("").Register("@john", "cash1234"); err == nil {
+		t.Errorf("Register() without an accounts file should fail")
+	}
+}
+
+func TestRegisterLogin(t *testing.T) {
+
+	oldAccounts := accountStore()
+	ACCOUNTS.Store(newAccountStore(filepath.Join(t.TempDir(), "accounts.db")))
+	defer ACCOUNTS.Store(oldAccounts)
+
+	if _, ok := CHANNELS.Load("#main"); !ok {
+		main_channel := NewChannelMain("#main")
+		CHANNELS.Store(main_channel.Key(), main_channel)
+	}
+
+	// send a line and read the reply, bcrypt can be slow
+	send := func(out net.Conn, in *bufio.Reader, line string) string {
+		out.SetReadDeadline(time.Now().Add(10 * time.Second))
+		out.Write([]byte(line + "\n"))
+
+		reply, _ := in.ReadString('\n')
+		return trim(reply)
+	}
+
+	expect := func(got string, want string) {
+		t.Helper()
+
+		if got != want {
+			t.Errorf("got %q, want %s", got, want)
+		}
+	}
+
+	// logoff and wait for the server to forget @spacey
+	logoff := func(out net.Conn, in *bufio.Reader) {
+		t.Helper()
+
+		expect(send(out, in, "/logoff"), ">/logoff>0>Goodbye @spacey")
+		waitDisconnected(t, "@spacey")
+	}
+
+	_, out, in := genClient()
+	defer out.Close()
+
+	expect(send(out, in, "/login @spacey"), ">/login>0>you're now @spacey")
+	expect(send(out, in, "/register open sesame"), ">/register>0>@spacey is now registered, use /login @spacey <password> next time")
+	logoff(out, in)
+
+	// the whole password is checked, spaces included
+	_, out, in = genClient()
+	defer out.Close()
+
+	expect(send(out, in, "/login @spacey open"), ">/login>0>wrong password for @spacey")
+	expect(send(out, in, "/login @spacey  open sesame "), ">/login>0>you're now @spacey")
+	logoff(out, in)
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// client roles. Commands require ROLE_USER unless stated in ROLES.
+const (
+	ROLE_USER  = 1 // everybody
+	ROLE_ADMIN = 2 // granted by the admins file or /admin <password>
+)
+
+const SHUTDOWN_DEFAULT = 5 // minutes before /shutdown stops the server
+
+// AdminList are the registered @names that become admins when they login
+// (one per line in the admins file) plus the bcrypt hash for /admin.
+type AdminList struct {
+	path         string          // admins file. Empty means no admin by name.
+	hash         string          // bcrypt hash of the /admin password. Empty disables /admin.
+	names        map[string]bool // @names of the admins
+	sync.RWMutex                 // for reading/reloading the admins
+}
+
+func newAdminList(path string, hash string) *AdminList {
+	return &AdminList{
+		path:    path,
+		hash:    hash,
+		names:   make(map[string]bool),
+		RWMutex: sync.RWMutex{},
+	}
+}
+
+// load the admins file. A missing file is an empty list.
+func (admins *AdminList) Load() error {
+
+	names := make(map[string]bool)
+
+	if !no(admins.path) {
+		file, err := os.Open(admins.path)
+
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+
+		if err == nil {
+			scanner := bufio.NewScanner(file)
+
+			for scanner.Scan() {
+				line := trim(scanner.Text())
+
+				if no(line) || line[0] == ';' {
+					continue
+				}
+
+				if _, err := ValidUsername(line); err != nil {
+					WARN.Printf("%s: ignoring admin %s (%s)", admins.path, line, err)
+					continue
+				}
+
+				names[line] = true
+			}
+
+			file.Close()
+
+			if err := scanner.Err(); err != nil {
+				return err
+			}
+		}
+	}
+
+	admins.Lock()
+	admins.names = names
+	admins.Unlock()
+
+	return nil
+}
+
+// is username in the admins file?
+func (admins *AdminList) Contains(username string) bool {
+	admins.RLock()
+	defer admins.RUnlock()
+
+	return admins.names[username]
+}
+
+// check the /admin password
+func (admins *AdminList) Check(password string) bool {
+
+	if no(admins.hash) {
+		return false
+	}
+
+	return bcrypt.CompareHashAndPassword([]byte(admins.hash), []byte(password)) == nil
+}
+
+func (clt *Client) isAdmin() bool {
+	return clt.Role.Load() == ROLE_ADMIN
+}
+
+// make clt an admin, telling them
+func (clt *Client) grantAdmin() {
+
+	clt.Role.Store(ROLE_ADMIN)
+	clt.Say(">#main>!admin>%s has now admin privileges", clt)
+
+	INFO.Printf("%s has now admin privileges", clt)
+}
+
+// get admin privileges with the admin password
+func do_admin(clt *Client, args string) {
+
+	if !clt.isLogged() {
+		clt.Say(">/admin>0>/admin requires you to be logged")
+
+		return
+	}
+
+	if no(args) {
+		clt.Say(">/admin>0>/admin <password>")
+
+		return
+	}
+
+	if !adminList().Check(args) {
+		clt.Say(">/admin>0>wrong admin password")
+		WARN.Printf("%s failed to get admin privileges (%s)", clt, clt.conn.RemoteAddr())
+
+		return
+	}
+
+	clt.grantAdmin()
+}
+
+// disconnect a user
+func do_kill(clt *Client, args string) {
+
+	username, reason := split2(args, " ")
+
+	if no(username) {
+		clt.Say(">/kill>0>/kill <@nick> [reason]")
+
+		return
+	}
+
+	target, ok := CLIENTS.Load(username)
+
+	if !ok {
+		clt.Say(">/kill>0>%s is not connected", username)
+
+		return
+	}
+
+	if no(reason) {
+		reason = "no reason given"
+	}
+
+	target.Say(">#main>!kill>you have been disconnected by %s: %s", clt, reason)
+	target.Status.Store(USER_LOGGINOUT)
+	target.UpdateInMain(">!kill>%s has been disconnected by %s", target, clt)
+
+	if target.isBot() {
+		target.Close() // bots have no clientLoop to clean up after them
+	} else {
+		target.conn.Close() // clientLoop of the target will clean up the client
+	}
+
+	clt.Say(">/kill>0>%s has been disconnected", target)
+
+	audit("kill", target.Name, "", clt.Name, reason, "")
+	WARN.Printf("%s killed %s (%s)", clt, target, reason)
+}
+
+// send a message to everyone connected
+func do_wall(clt *Client, args string) {
+
+	if no(args) {
+		clt.Say(">/wall>0>/wall <text>")
+
+		return
+	}
+
+	Broadcast(">#main>!wall>%s: %s", clt, args)
+}
+
+// stop the server after some minutes, or cancel a shutdown in progress
+func do_shutdown(clt *Client, args string) {
+
+	if args == "cancel" {
+		if !cancelShutdown() {
+			clt.Say(">/shutdown>0>there is no shutdown in progress")
+			return
+		}
+
+		Broadcast(">#main>!shutdown>shutdown cancelled by %s", clt)
+		WARN.Printf("%s cancelled the shutdown", clt)
+
+		return
+	}
+
+	minutes := SHUTDOWN_DEFAULT
+
+	if !no(args) {
+		n, err := strconv.Atoi(args)
+
+		if err != nil || n < 0 {
+			clt.Say(">/shutdown>0>/shutdown [minutes|cancel]")
+			return
+		}
+
+		minutes = n
+	}
+
+	if !scheduleShutdown(minutes) {
+		clt.Say(">/shutdown>0>there is already a shutdown in progress, /shutdown cancel first")
+		return
+	}
+
+	WARN.Printf("%s scheduled a shutdown in %d minutes", clt, minutes)
+}
+
+// read again the configuration, accounts and admins files
+func do_reload(clt *Client, args string) {
+
+	if err := reloadConfig(); err != nil {
+		clt.Say(">/reload>0>unable to reload: %s", err)
+
+		return
+	}
+
+	clt.Say(">/reload>0>configuration reloaded")
+	INFO.Printf("%s reloaded the configuration", clt)
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+)
+
+const AUDIT_SIZE = 1000 // entries kept in memory for /audit
+
+// AuditEntry is a line of the audit log
+type AuditEntry struct {
+	Time    time.Time `json:"time"`
+	Event   string    `json:"event"` // login, logout, join, leave, kick, ban, kill
+	User    string    `json:"user"`
+	Channel string    `json:"channel,omitempty"`
+	By      string    `json:"by,omitempty"`   // who kicked, banned... the user
+	Addr    string    `json:"addr,omitempty"` // remote address on login
+	Reason  string    `json:"reason,omitempty"`
+}
+
+// AuditLog writes the audit entries as json lines to a file, and keeps the
+// last AUDIT_SIZE ones for the http admin port
+type AuditLog struct {
+	file       *os.File // nil if there's no audit file
+	recent     [][]byte // ring buffer of json lines
+	next       int      // position where the next line will be stored
+	sync.Mutex          // for writing entries and reopening the file
+}
+
+var AUDIT = &AuditLog{Mutex: sync.Mutex{}}
+
+// (re)open the audit file, so it can be rotated with a reload. Empty path
+// keeps the audit only in memory.
+func (audit *AuditLog) Open(path string) error {
+
+	var file *os.File
+
+	if !no(path) {
+		var err error
+
+		file, err = os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	audit.Lock()
+	defer audit.Unlock()
+
+	if audit.file != nil {
+		audit.file.Close()
+	}
+
+	audit.file = file
+
+	return nil
+}
+
+// add an entry to the log
+func (audit *AuditLog) Add(entry AuditEntry) {
+
+	if entry.Time.IsZero() {
+		entry.Time = time.Now().UTC()
+	}
+
+	line, err := json.Marshal(entry)
+
+	if err != nil {
+		ERROR.Printf("unable to encode audit entry %+v (%s)", entry, err)
+		return
+	}
+
+	line = append(line, '\n')
+
+	audit.Lock()
+	defer audit.Unlock()
+
+	if len(audit.recent) < AUDIT_SIZE {
+		audit.recent = append(audit.recent, line)
+	} else {
+		audit.recent[audit.next] = line
+	}
+
+	audit.next = (audit.next + 1) % AUDIT_SIZE
+
+	if audit.file == nil {
+		return
+	}
+
+	if _, err := audit.file.Write(line); err != nil {
+		WARN.Printf("unable to write audit log %s (%s)", audit.file.Name(), err)
+	}
+}
+
+// the entries kept in memory, oldest first
+func (audit *AuditLog) Recent() []byte {
+	audit.Lock()
+	defer audit.Unlock()
+
+	var output []byte
+
+	for i := range audit.recent {
+		if len(audit.recent) < AUDIT_SIZE {
+			output = append(output, audit.recent[i]...)
+		} else {
+			output = append(output, audit.recent[(audit.next+i)%AUDIT_SIZE]...)
+		}
+	}
+
+	return output
+}
+
+// add an entry to the audit log, empty fields are left out
+func audit(event string, user string, channel string, by string, reason string, addr string) {
+	AUDIT.Add(AuditEntry{Event: event, User: user, Channel: channel, By: by, Reason: reason, Addr: addr})
+}
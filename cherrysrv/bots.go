@@ -0,0 +1,301 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Bot is a chat bot running inside the server. It is a logged user like
+// any other (it shows in /users, can join channels and be /msg'ed) but it
+// talks to the server through Go calls instead of a socket.
+type Bot interface {
+	Name() string // @name of the bot
+
+	// called once the bot is logged in, to join channels and add commands
+	Start(bot *BotClient) error
+
+	// called with every line said in a channel the bot is in (to is the
+	// #channel) or sent to the bot with /msg (to is the @name of the bot).
+	// Lines said by the bot itself are not received.
+	OnMessage(bot *BotClient, to string, from string, text string)
+}
+
+const BOT_INBOX = 100 // lines waiting for a slow bot before they are dropped
+
+// BotClient is the client of a bot. Its lines are handled in a goroutine of
+// its own, so bots can Say from OnMessage.
+type BotClient struct {
+	*Client
+	bot Bot
+}
+
+// command added by a bot, shown in /help
+type BotCommand struct {
+	usage string // as in /help
+	bot   string // @name of the bot that added it
+}
+
+var (
+	BOT_COMMANDS = make(map[string]BotCommand) // command -> bot that added it
+	botsLock     sync.Mutex                    // for BOT_COMMANDS
+)
+
+// log a bot in and start it
+func startBot(bot Bot) (*BotClient, error) {
+
+	name := bot.Name()
+
+	if _, err := ValidUsername(name); err != nil {
+		return nil, fmt.Errorf("%s is not a valid bot name: %s", name, err)
+	}
+
+	if accountStore().Exists(name) {
+		return nil, fmt.Errorf("%s is a registered account", name)
+	}
+
+	conn := newBotConn(name)
+
+	clt := &Client{
+		conn:      conn,
+		Name:      name,
+		flood:     newFloodControl(config().Flood, time.Now()),
+		connected: time.Now(),
+	}
+	clt.Status.Store(USER_LOGGED)
+	clt.Role.Store(ROLE_USER)
+	clt.touch()
+
+	if _, loaded := CLIENTS.LoadOrStore(clt.Key(), clt); loaded {
+		return nil, fmt.Errorf("%s is already taken", name)
+	}
+
+	botClient := &BotClient{Client: clt, bot: bot}
+
+	if mainChannel, ok := CHANNELS.Load("#main"); ok {
+		mainChannel.addClient(clt)
+	}
+
+	go botClient.loop(conn)
+
+	if err := bot.Start(botClient); err != nil {
+		clt.Close()
+		return nil, err
+	}
+
+	INFO.Printf("bot %s has started", clt)
+
+	return botClient, nil
+}
+
+// deliver the lines written to the bot
+func (botClient *BotClient) loop(conn *botConn) {
+
+	for line := range conn.inbox {
+		to, from, text, ok := parseBotLine(line)
+
+		if !ok || from == botClient.Name {
+			continue
+		}
+
+		botClient.bot.OnMessage(botClient, to, from, text)
+	}
+}
+
+// >#channel>@from>text or >@from>@bot>text (private message). Replies to
+// commands and events are not for bots.
+func parseBotLine(line string) (to string, from string, text string, ok bool) {
+
+	first, second, text, ok := splitLine(line)
+
+	switch {
+	case !ok:
+		return "", "", "", false
+	case strings.HasPrefix(first, "#") && strings.HasPrefix(second, "@"):
+		return first, second, text, true
+	case strings.HasPrefix(first, "@") && strings.HasPrefix(second, "@"):
+		return second, first, text, true // private message
+	}
+
+	return "", "", "", false
+}
+
+// join a channel, creating it if needed
+func (botClient *BotClient) Join(channelName string) error {
+
+	if channel, ok := CHANNELS.Load(channelName); ok {
+		if channel.contains(botClient.Client) {
+			return nil
+		}
+
+		if err := channel.canJoin(botClient.Client); err != nil {
+			return err
+		}
+
+		if !channel.addClient(botClient.Client) {
+			return fmt.Errorf("%s is shutting down", channel)
+		}
+
+		audit("join", botClient.Name, channel.Name, "", "", "")
+
+		return nil
+	}
+
+	if _, err := ValidChannelname(channelName); err != nil {
+		return err
+	}
+
+	channel := newChannel(channelName, false)
+	channel.addClient(botClient.Client)
+
+	if _, loaded := CHANNELS.LoadOrStore(channel.Key(), channel); loaded {
+		return botClient.Join(channelName) // created meanwhile by someone else
+	}
+
+	audit("join", botClient.Name, channel.Name, "", "", "")
+	DEBUG.Printf("adding %s to CHANNELS", channel)
+
+	return nil
+}
+
+// say something in a channel the bot is in
+func (botClient *BotClient) Say(channelName string, format string, args ...interface{}) error {
+
+	channel, ok := CHANNELS.Load(channelName)
+
+	if !ok || !channel.contains(botClient.Client) {
+		return fmt.Errorf("%s is not in %s", botClient, channelName)
+	}
+
+	channel.Say(botClient.Client, format, args...)
+
+	return nil
+}
+
+// send a private message to a logged user
+func (botClient *BotClient) Msg(username string, format string, args ...interface{}) error {
+
+	target, ok := findLoggedClient(username)
+
+	if !ok {
+		return fmt.Errorf("%s is not online", username)
+	}
+
+	target.Say("%s", messageLine(botClient.Name, target.Name, fmt.Sprintf(format, args...)))
+	METRICS.Message()
+
+	return nil
+}
+
+// add a command to COMMANDS. Only to be called from Start, COMMANDS is not
+// locked while the server runs.
+func (botClient *BotClient) AddCommand(command string, usage string, do func(clt *Client, args string)) error {
+	botsLock.Lock()
+	defer botsLock.Unlock()
+
+	if _, ok := COMMANDS[command]; ok {
+		return fmt.Errorf("command /%s already exists", command)
+	}
+
+	COMMANDS[command] = do
+	BOT_COMMANDS[command] = BotCommand{usage: usage, bot: botClient.Name}
+
+	return nil
+}
+
+// lines of /help for the commands added by bots
+func botHelp() (lines []string) {
+	botsLock.Lock()
+	defer botsLock.Unlock()
+
+	for command, added := range BOT_COMMANDS {
+		lines = append(lines, fmt.Sprintf("%-26s - (%s) %s", "/"+command, added.bot, added.usage))
+	}
+
+	sort.Strings(lines)
+
+	return lines
+}
+
+func (clt *Client) isBot() bool {
+	_, ok := clt.conn.(*botConn)
+	return ok
+}
+
+// botConn is the net.Conn of a bot: what the server writes is split in
+// lines for the bot, and there's nothing to read.
+type botConn struct {
+	name    string
+	inbox   chan string
+	partial string // line without \n yet
+	closed  chan struct{}
+	sync.Mutex
+}
+
+func newBotConn(name string) *botConn {
+	return &botConn{
+		name:   name,
+		inbox:  make(chan string, BOT_INBOX),
+		closed: make(chan struct{}),
+	}
+}
+
+func (conn *botConn) Write(p []byte) (int, error) {
+	conn.Lock()
+	defer conn.Unlock()
+
+	select {
+	case <-conn.closed:
+		return 0, net.ErrClosed
+	default:
+	}
+
+	lines := strings.Split(conn.partial+string(p), "\n")
+	conn.partial = lines[len(lines)-1]
+
+	for _, line := range lines[:len(lines)-1] {
+		select {
+		case conn.inbox <- line:
+		default:
+			WARN.Printf("bot %s is too slow, dropping %q", conn.name, line)
+		}
+	}
+
+	return len(p), nil
+}
+
+// bots are never read, this blocks until the bot is closed
+func (conn *botConn) Read(p []byte) (int, error) {
+	<-conn.closed
+	return 0, io.EOF
+}
+
+func (conn *botConn) Close() error {
+	conn.Lock()
+	defer conn.Unlock()
+
+	select {
+	case <-conn.closed:
+		return net.ErrClosed
+	default:
+		close(conn.closed)
+		close(conn.inbox)
+	}
+
+	return nil
+}
+
+type botAddr string
+
+func (addr botAddr) Network() string { return "bot" }
+func (addr botAddr) String() string  { return string(addr) }
+
+func (conn *botConn) LocalAddr() net.Addr                { return botAddr("bot") }
+func (conn *botConn) RemoteAddr() net.Addr               { return botAddr("bot:" + conn.name) }
+func (conn *botConn) SetDeadline(t time.Time) error      { return nil }
+func (conn *botConn) SetReadDeadline(t time.Time) error  { return nil }
+func (conn *botConn) SetWriteDeadline(t time.Time) error { return nil }
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// echoBot repeats in uppercase what it's told
+type echoBot struct {
+	received chan string
+}
+
+func (echo *echoBot) Name() string {
+	return "@echo"
+}
+
+func (echo *echoBot) Start(bot *BotClient) error {
+
+	if err := bot.Join("#bots"); err != nil {
+		return err
+	}
+
+	return bot.AddCommand("echotest", "<text> echo text", func(clt *Client, args string) {
+		clt.Say(">/echotest>0>%s", strings.ToUpper(args))
+	})
+}
+
+func (echo *echoBot) OnMessage(bot *BotClient, to string, from string, text string) {
+
+	echo.received <- fmt.Sprintf("%s %s %s", to, from, text)
+
+	if to == bot.Name {
+		bot.Msg(from, "%s", strings.ToUpper(text))
+		return
+	}
+
+	bot.Say(to, "%s", strings.ToUpper(text))
+}
+
+func TestBot(t *testing.T) {
+
+	echo := &echoBot{received: make(chan string, 10)}
+
+	bot, err := startBot(echo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		bot.Close()
+		delete(COMMANDS, "echotest")
+		delete(BOT_COMMANDS, "echotest")
+	}()
+
+	if _, err := startBot(echo); err == nil {
+		t.Errorf("a second bot with the same @name should fail")
+	}
+
+	server, out := net.Pipe()
+	defer out.Close()
+
+	clt := newClient(server)
+	CLIENTS.Delete(clt.Key())
+	clt.Name = "@human"
+	clt.Status.Store(USER_LOGGED)
+	CLIENTS.Store(clt.Key(), clt)
+	defer clt.Close()
+
+	channel, _ := CHANNELS.Load("#bots")
+	channel.addClient(clt)
+
+	lines := bufio.NewReader(out)
+	out.SetReadDeadline(time.Now().Add(2 * time.Second))
+
+	expect := func(want string) {
+		t.Helper()
+
+		line, err := lines.ReadString('\n')
+
+		if err != nil || strings.TrimRight(line, "\n") != want {
+			t.Errorf("got %q (%v), want %q", line, err, want)
+		}
+	}
+
+	// a channel message goes to the bot, and the bot answers in the channel
+	go channel.Say(clt, "hello bots")
+	expect(">#bots>@human>hello bots")
+	expect(">#bots>@echo>HELLO BOTS")
+
+	// a private message is answered in private, maybe before the /msg reply
+	go exec(clt, "msg", "@echo psst")
+
+	answers := map[string]bool{}
+
+	for i := 0; i < 2; i++ {
+		line, _ := lines.ReadString('\n')
+		answers[strings.TrimRight(line, "\n")] = true
+	}
+
+	if !answers[">@echo>@human>PSST"] || !answers[">/msg>0>message sent to @echo"] {
+		t.Errorf("got %v, want the private answer and the /msg reply", answers)
+	}
+
+	for _, want := range []string{"#bots @human hello bots", "@echo @human psst"} {
+		if got := <-echo.received; got != want {
+			t.Errorf("bot received %q, want %q", got, want)
+		}
+	}
+
+	go exec(clt, "echotest", "added by a bot")
+	expect(">/echotest>0>ADDED BY A BOT")
+
+	go exec(clt, "users", "#bots")
+	expect(">/users #bots>1>@echo (bot)")
+	expect(">/users #bots>0>@human")
+}
+
+// idleBot does nothing but being logged in
+type idleBot struct{}
+
+func (idle *idleBot) Name() string                                    { return "@idle" }
+func (idle *idleBot) Start(bot *BotClient) error                      { return nil }
+func (idle *idleBot) OnMessage(bot *BotClient, to, from, text string) {}
+
+func TestKillBot(t *testing.T) {
+
+	bot, err := startBot(&idleBot{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server, out := net.Pipe()
+	defer out.Close()
+
+	clt := newClient(server)
+	CLIENTS.Delete(clt.Key())
+	clt.Name = "@admin"
+	clt.Status.Store(USER_LOGGED)
+	clt.Role.Store(ROLE_ADMIN)
+	CLIENTS.Store(clt.Key(), clt)
+	defer clt.Close()
+
+	lines := bufio.NewReader(out)
+	out.SetReadDeadline(time.Now().Add(2 * time.Second))
+
+	go exec(clt, "kill", "@idle testing")
+
+	for _, want := range []string{">#main>!kill>@idle has been disconnected by @admin", ">/kill>0>@idle has been disconnected"} {
+		if line, err := lines.ReadString('\n'); err != nil || strings.TrimRight(line, "\n") != want {
+			t.Errorf("got %q (%v), want %q", line, err, want)
+		}
+	}
+
+	if _, ok := CLIENTS.Load("@idle"); ok {
+		t.Errorf("a killed bot should not be connected")
+	}
+
+	if mainChannel, ok := CHANNELS.Load("#main"); ok && mainChannel.contains(bot.Client) {
+		t.Errorf("a killed bot should not be in #main")
+	}
+
+	again, err := startBot(&idleBot{})
+	if err != nil {
+		t.Fatalf("the @name of a killed bot should be free again: %v", err)
+	}
+
+	again.Close()
+}
+
+func TestRoll(t *testing.T) {
+
+	tests := []struct {
+		spec string
+		want string // regexp
+	}{
+		{"", `^1d6: [1-6]$`},
+		{"d20", `^d20: ([1-9]|1[0-9]|20)$`},
+		{"3d6", `^3d6: [1-6] \+ [1-6] \+ [1-6] = ([3-9]|1[0-8])$`},
+		{"0d6", `^dice are NdM`},
+		{"2d1", `^dice are NdM`},
+		{"lots", `^dice are NdM`},
+	}
+
+	for _, test := range tests {
+		result, err := roll(test.spec)
+
+		if err != nil {
+			result = err.Error()
+		}
+
+		if !regexp.MustCompile(test.want).MatchString(result) {
+			t.Errorf("roll(%q) = %q, want %s", test.spec, result, test.want)
+		}
+	}
+}
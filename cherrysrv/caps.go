@@ -0,0 +1,274 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Caps are the capabilities declared by a client with /caps. Old clients
+// never declare anything and get the protocol as it has always been.
+type Caps struct {
+	EOL      string // line ending sent to the client
+	MaxLine  int    // max length of the lines read from and written to the client
+	Compact  bool   // events use the short codes in EVENT_CODES
+	Platform string // computer of the client (atari, c64...), shown in /whois
+}
+
+const (
+	MAX_LINE     = 255  // protocol default
+	MAX_LINE_CAP = 1024 // longest line a client can ask for
+)
+
+var DEFAULT_CAPS = Caps{EOL: "\n", MaxLine: MAX_LINE, Compact: false}
+
+// line endings a client can ask for
+var EOLS = map[string]string{
+	"lf":      "\n",
+	"crlf":    "\r\n",
+	"cr":      "\r",
+	"atascii": "\x9b",
+}
+
+// short codes of the events for clients with events=compact
+var EVENT_CODES = map[string]string{
+	"admin":      "a",
+	"ban":        "b",
+	"disconnect": "d",
+	"flood":      "f",
+	"game":       "g",
+	"idle":       "i",
+	"invite":     "I",
+	"irc":        "r",
+	"kick":       "k",
+	"kill":       "K",
+	"login":      "l",
+	"logoff":     "L",
+	"mail":       "e",
+	"mode":       "M",
+	"motd":       "m",
+	"op":         "o",
+	"ping":       "p",
+	"shutdown":   "s",
+	"topic":      "t",
+	"wall":       "W",
+	"welcome":    "w",
+}
+
+// capabilities of the client, DEFAULT_CAPS if it declared none
+func (clt *Client) Caps() Caps {
+
+	if caps := clt.caps.Load(); caps != nil {
+		return *caps
+	}
+
+	return DEFAULT_CAPS
+}
+
+// encode a line and cut it to the max length of the client, keeping its end
+// of line. The cut is made on the bytes sent: folded glyphs and the eol may
+// be longer than the text they come from.
+func (clt *Client) shorten(line string) []byte {
+
+	data := clt.encode(line)
+	max := clt.Caps().MaxLine
+
+	if len(data) <= max {
+		return data
+	}
+
+	eol := clt.encode("\n")
+	cut := max - len(eol)
+
+	if clt.Charset() == nil {
+		cut = len(cutRunes(string(data), cut))
+	}
+
+	return append(data[:cut:cut], eol...)
+}
+
+// translate what the server says to what the client declared it understands
+func (clt *Client) encode(text string) []byte {
+
+	caps := clt.Caps()
+
+	if caps.Compact {
+		lines := strings.SplitAfter(text, "\n")
+
+		for i, line := range lines {
+			lines[i] = compactEvent(line)
+		}
+
+		text = strings.Join(lines, "")
+	}
+
+	text = clt.Charset().Encode(text)
+
+	if caps.EOL != "\n" {
+		text = strings.ReplaceAll(text, "\n", caps.EOL)
+	}
+
+	return []byte(text)
+}
+
+// >#channel>!event>text -> >#channel>!code>text
+func compactEvent(line string) string {
+
+	channel, event, text, ok := splitLine(line)
+
+	if !ok || !strings.HasPrefix(channel, "#") || !strings.HasPrefix(event, "!") {
+		return line
+	}
+
+	code, ok := EVENT_CODES[event[1:]]
+
+	if !ok {
+		return line
+	}
+
+	if strings.HasSuffix(line, "\n") {
+		return eventLine(channel, code, text) + "\n"
+	}
+
+	return eventLine(channel, code, text)
+}
+
+// sorted "key=value" of a map, or just the keys if values is false
+func sortedKeys(m map[string]string, values bool) []string {
+
+	keys := make([]string, 0, len(m))
+
+	for key, value := range m {
+		if values {
+			key += "=" + value
+		}
+
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+// commands available to the client, sorted
+func availableCommands(clt *Client) []string {
+
+	commands := make([]string, 0, len(COMMANDS))
+
+	for command := range COMMANDS {
+		if role, ok := ROLES[command]; ok && clt.Role.Load() < int32(role) {
+			continue
+		}
+
+		commands = append(commands, command)
+	}
+
+	sort.Strings(commands)
+
+	return commands
+}
+
+// "key value value..." lines, split so they fit in a protocol line
+func capsLines(key string, values []string) (lines []string) {
+
+	line := key
+
+	for _, value := range values {
+		if len(line)+1+len(value) > 200 {
+			lines = append(lines, line)
+			line = key
+		}
+
+		line += " " + value
+	}
+
+	return append(lines, line)
+}
+
+func (caps Caps) String() string {
+
+	eol := "lf"
+
+	for name, value := range EOLS {
+		if value == caps.EOL {
+			eol = name
+		}
+	}
+
+	events := "full"
+
+	if caps.Compact {
+		events = "compact"
+	}
+
+	if !no(caps.Platform) {
+		return fmt.Sprintf("eol=%s maxline=%d events=%s platform=%s", eol, caps.MaxLine, events, caps.Platform)
+	}
+
+	return fmt.Sprintf("eol=%s maxline=%d events=%s", eol, caps.MaxLine, events)
+}
+
+// show the capabilities of the server, or declare the ones of the client
+// as key=value pairs (eol, maxline, events, charset, platform)
+func do_caps(clt *Client, args string) {
+
+	if no(args) {
+		var lines []string
+
+		protocol := strings.Join(strings.SplitN(VERSION, ".", 3)[:2], ".") // MAJOR.MINOR, see README
+
+		lines = append(lines, "protocol "+protocol, "version "+VERSION)
+		lines = append(lines, fmt.Sprintf("maxline %d %d", MAX_LINE, MAX_LINE_CAP))
+		lines = append(lines, capsLines("charsets", charsetNames())...)
+		lines = append(lines, capsLines("eol", sortedKeys(EOLS, false))...)
+		lines = append(lines, capsLines("events", sortedKeys(EVENT_CODES, true))...)
+		lines = append(lines, capsLines("commands", availableCommands(clt))...)
+		lines = append(lines, fmt.Sprintf("client %s charset=%s", clt.Caps(), clt.Charset()))
+
+		clt.SayN(">/caps>", lines)
+
+		return
+	}
+
+	caps := clt.Caps()
+	charset := clt.Charset()
+
+	for _, field := range strings.Fields(args) {
+		key, value := split2(field, "=")
+		value = strings.ToLower(value)
+
+		var ok bool
+
+		switch strings.ToLower(key) {
+		case "eol":
+			caps.EOL, ok = EOLS[value]
+		case "maxline":
+			n, err := strconv.Atoi(value)
+			ok = err == nil && n >= MAX_LINE && n <= MAX_LINE_CAP
+			caps.MaxLine = n
+		case "events":
+			ok = value == "compact" || value == "full"
+			caps.Compact = value == "compact"
+		case "charset":
+			charset, ok = CHARSETS[value]
+		case "platform":
+			ok = !no(value) && len(value) <= PROFILE_KEYS["platform"] && isASCIIPrintable(value)
+			caps.Platform = value
+		default:
+			clt.Say(">/caps>0>unknown capability %s", key)
+			return
+		}
+
+		if !ok {
+			clt.Say(">/caps>0>%s cannot be %s", key, value)
+			return
+		}
+	}
+
+	clt.caps.Store(&caps)
+	clt.charset.Store(charset)
+
+	clt.Say(">/caps>0>client %s charset=%s", caps, charset)
+}
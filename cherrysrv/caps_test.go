@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestCapsEncode(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		caps    Caps
+		charset string
+		text    string
+		want    string
+	}{
+		{"default", DEFAULT_CAPS, "utf8", ">#main>!welcome>hi\n", ">#main>!welcome>hi\n"},
+		{"compact", Caps{EOL: "\n", MaxLine: MAX_LINE, Compact: true}, "utf8",
+			">#main>!welcome>hi\n>#main>@bob>!welcome>\n>#retro>!topic>games\n",
+			">#main>!w>hi\n>#main>@bob>!welcome>\n>#retro>!t>games\n"},
+		{"unknown event", Caps{EOL: "\n", MaxLine: MAX_LINE, Compact: true}, "utf8", ">#main>!new>x\n", ">#main>!new>x\n"},
+		{"crlf", Caps{EOL: "\r\n", MaxLine: MAX_LINE}, "utf8", ">/who>0>@bob\n", ">/who>0>@bob\r\n"},
+		{"atascii eol", Caps{EOL: "\x9b", MaxLine: MAX_LINE}, "atascii", ">/who>0>@bob ♥\n", ">/who>0>@bob \x00\x9b"},
+	}
+
+	for _, test := range tests {
+		clt := &Client{}
+		clt.caps.Store(&test.caps)
+		clt.charset.Store(CHARSETS[test.charset])
+
+		if got := string(clt.encode(test.text)); got != test.want {
+			t.Errorf("%s: encode(%q) = %q, want %q", test.name, test.text, got, test.want)
+		}
+	}
+}
@@ -16,11 +16,21 @@ type Channel struct {
 	clients      []*Client // clients in the channel.
 	Name         string    // Name of the channel (incl #)
 	hidden       bool
-	closeOnEmpty bool // only #main should have this as false
-	Status       int  // CHANNEL_WORKING, CHANNEL_SHUTTINGDOWN
-	sync.RWMutex      // for adding/removing client connections
+	closeOnEmpty bool     // only #main should have this as false
+	Status       int      // CHANNEL_WORKING, CHANNEL_SHUTTINGDOWN
+	history      *History // last lines said in the channel
+	topic        string
+	ops          map[string]bool // @names of the operators
+	bans         map[string]bool // @names that cannot join
+	invited      map[string]bool // @names that can join an invite only channel
+	inviteOnly   bool
+	listeners    map[string]ChannelListener // called with every line said, by name
+	sync.RWMutex                            // for adding/removing client connections
 }
 
+// ChannelListener gets the lines said in a channel (as the irc bridge)
+type ChannelListener func(channel *Channel, from string, message string)
+
 func newChannel(name string, hiddenChannel bool) *Channel {
 	return &Channel{
 		clients:      []*Client{},
@@ -28,6 +38,11 @@ func newChannel(name string, hiddenChannel bool) *Channel {
 		hidden:       hiddenChannel,
 		closeOnEmpty: true,
 		Status:       CHANNEL_WORKING,
+		history:      newHistory(name),
+		ops:          make(map[string]bool),
+		bans:         make(map[string]bool),
+		invited:      make(map[string]bool),
+		listeners:    make(map[string]ChannelListener),
 		RWMutex:      sync.RWMutex{},
 	}
 
@@ -40,6 +55,11 @@ func NewChannelMain(name string) *Channel {
 		hidden:       false,
 		closeOnEmpty: false,
 		Status:       CHANNEL_WORKING,
+		history:      newHistory(name),
+		ops:          make(map[string]bool),
+		bans:         make(map[string]bool),
+		invited:      make(map[string]bool),
+		listeners:    make(map[string]ChannelListener),
 
 		RWMutex: sync.RWMutex{},
 	}
@@ -81,6 +101,22 @@ func (c *Channel) ClientNames() (output []string) {
 	return output
 }
 
+// return the clients currently in this channel as shown in /users
+func (c *Channel) UserLines() (output []string) {
+	c.RLock()
+	defer c.RUnlock()
+
+	for _, client := range c.clients {
+		if client.Status.Load() != USER_LOGGINOUT {
+			output = append(output, client.UserLine())
+		}
+	}
+
+	sort.Strings(output)
+
+	return output
+}
+
 // find if a certain client is in this channel
 func (c *Channel) contains(client *Client) bool {
 	c.RLock()
@@ -119,8 +155,7 @@ func (channel *Channel) removeClient(client *Client) bool {
 
 	// len(c.clients) = 0 or 1, we manage them as special cases
 
-	if len == 0 {
-		DEBUG.Printf("%s has 0 clients and this should not be possible", channel)
+	if len == 0 { // permanent channels (#main, channels in the config) can be empty
 		return false
 	}
 
@@ -130,6 +165,7 @@ func (channel *Channel) removeClient(client *Client) bool {
 			channel.Status = CHANNEL_SHUTTINGDOWN
 		}
 		channel.clients = []*Client{}
+		delete(channel.ops, client.Name)
 
 		if channel.closeOnEmpty {
 			DEBUG.Printf("%s has now 0 clients, removing it from the directory", channel)
@@ -148,6 +184,7 @@ func (channel *Channel) removeClient(client *Client) bool {
 		if channel.clients[i] == client {
 			channel.clients[i] = channel.clients[len-1]
 			channel.clients = channel.clients[:len-1]
+			delete(channel.ops, client.Name)
 
 			return true
 		}
@@ -156,6 +193,85 @@ func (channel *Channel) removeClient(client *Client) bool {
 	return false
 }
 
+// check if client can join the channel, returning the reason if not
+func (channel *Channel) canJoin(client *Client) error {
+	channel.RLock()
+	defer channel.RUnlock()
+
+	if channel.bans[client.Name] {
+		return fmt.Errorf("you are banned from %s", channel)
+	}
+
+	if channel.inviteOnly && !channel.invited[client.Name] {
+		return fmt.Errorf("%s is invite only", channel)
+	}
+
+	return nil
+}
+
+func (channel *Channel) isOp(client *Client) bool {
+	channel.RLock()
+	defer channel.RUnlock()
+
+	return channel.ops[client.Name]
+}
+
+func (channel *Channel) setOp(client *Client) {
+	channel.Lock()
+	defer channel.Unlock()
+
+	channel.ops[client.Name] = true
+}
+
+func (channel *Channel) Topic() string {
+	channel.RLock()
+	defer channel.RUnlock()
+
+	return channel.topic
+}
+
+func (channel *Channel) setTopic(topic string) {
+	channel.Lock()
+	defer channel.Unlock()
+
+	channel.topic = topic
+}
+
+// ban (or unban) an @name from joining the channel
+func (channel *Channel) setBan(name string, banned bool) {
+	channel.Lock()
+	defer channel.Unlock()
+
+	if banned {
+		channel.bans[name] = true
+		return
+	}
+
+	delete(channel.bans, name)
+}
+
+func (channel *Channel) invite(name string) {
+	channel.Lock()
+	defer channel.Unlock()
+
+	channel.invited[name] = true
+}
+
+func (channel *Channel) setInviteOnly(inviteOnly bool) {
+	channel.Lock()
+	defer channel.Unlock()
+
+	channel.inviteOnly = inviteOnly
+}
+
+// send an event to everyone in the channel
+func (channel *Channel) Event(event string, format string, args ...interface{}) {
+
+	message := fmt.Sprintf(format, args...)
+
+	channel.write(nil, eventLine(channel.Name, event, message)+"\n")
+}
+
 func (channel *Channel) Say(from *Client, format string, args ...interface{}) {
 
 	message := fmt.Sprintf(format, args...)
@@ -164,7 +280,55 @@ func (channel *Channel) Say(from *Client, format string, args ...interface{}) {
 		return
 	}
 
-	channel.write(from, ">"+channel.Name+">"+from.Name+">"+message+"\n")
+	channel.say("", from.Name, message)
+}
+
+// say a message in the name of someone without a client (as an irc user).
+// The listener saying it is not called back.
+func (channel *Channel) SayAs(listener string, from string, message string) {
+
+	if len(message) == 0 {
+		return
+	}
+
+	channel.say(listener, from, message)
+}
+
+func (channel *Channel) say(listener string, from string, message string) {
+
+	METRICS.Message()
+	channel.history.Add(from + ">" + message)
+
+	channel.write(nil, messageLine(channel.Name, from, message)+"\n")
+
+	var listeners []ChannelListener
+
+	channel.RLock()
+	for name, listen := range channel.listeners {
+		if name != listener {
+			listeners = append(listeners, listen)
+		}
+	}
+	channel.RUnlock()
+
+	for _, listen := range listeners {
+		listen(channel, from, message)
+	}
+}
+
+// call listen with every line said in the channel. The channel is made
+// permanent, otherwise the listener would be lost when the channel empties.
+func (channel *Channel) Listen(name string, listen ChannelListener) {
+	channel.Lock()
+	defer channel.Unlock()
+
+	channel.closeOnEmpty = false
+	channel.listeners[name] = listen
+}
+
+// return the last n lines said in the channel, oldest first
+func (channel *Channel) History(n int) []string {
+	return channel.history.Last(n)
 }
 
 func (c *Channel) write(from *Client, message string) {
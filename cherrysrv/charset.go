@@ -0,0 +1,330 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// Charset translates between the unicode text used inside the server and the
+// native 8 bit characters of a client. Unicode without an exact glyph folds to
+// the closest one (é -> e, ╔ -> ┌ -> +) and ends as '?' if there's none.
+type Charset struct {
+	Name   string
+	decode [256]rune     // native byte -> unicode. 0 drops the byte (control codes).
+	encode map[rune]byte // unicode -> native byte
+	eol    byte          // native end of line, ends a line as \n does
+}
+
+const CHARSET_DEFAULT = "utf8" // no translation
+
+var CHARSETS = map[string]*Charset{
+	"utf8":    nil,
+	"ascii":   newCharset("ascii", asciiTable()),
+	"atascii": atascii(),
+	"petscii": petscii(),
+	"cp437":   newCharset("cp437", cp437Table()),
+}
+
+// build a charset from its decoding table. When several bytes decode to the
+// same rune, the lowest one is used to encode it.
+func newCharset(name string, decode [256]rune) *Charset {
+
+	charset := &Charset{
+		Name:   name,
+		decode: decode,
+		encode: make(map[rune]byte),
+		eol:    '\n',
+	}
+
+	for b, r := range decode {
+		if _, ok := charset.encode[r]; r != 0 && !ok {
+			charset.encode[r] = byte(b)
+		}
+	}
+
+	return charset
+}
+
+// a line sent by the client as unicode text. The line was already split at
+// its end, so any end of line left inside it is dropped: it would fake lines
+// of the protocol.
+func (charset *Charset) Decode(data string) string {
+
+	if charset == nil {
+		return strings.NewReplacer("\r", "", "\n", "").Replace(data)
+	}
+
+	var output strings.Builder
+
+	for i := 0; i < len(data); i++ {
+		if r := charset.decode[data[i]]; r != 0 && r != '\r' && r != '\n' {
+			output.WriteRune(r)
+		}
+	}
+
+	return output.String()
+}
+
+// byte ending the lines sent by the client, besides \n
+func (charset *Charset) EOL() byte {
+
+	if charset == nil {
+		return '\n'
+	}
+
+	return charset.eol
+}
+
+// unicode text as the bytes the client understands
+func (charset *Charset) Encode(text string) string {
+
+	if charset == nil {
+		return text
+	}
+
+	var output strings.Builder
+
+	for _, r := range text {
+		charset.encodeRune(&output, r, 0)
+	}
+
+	return output.String()
+}
+
+func (charset *Charset) encodeRune(output *strings.Builder, r rune, depth int) {
+
+	if b, ok := charset.encode[r]; ok {
+		output.WriteByte(b)
+		return
+	}
+
+	if fold, ok := FOLD[r]; ok && depth < 3 {
+		for _, f := range fold {
+			charset.encodeRune(output, f, depth+1)
+		}
+		return
+	}
+
+	output.WriteByte('?')
+}
+
+func (charset *Charset) String() string {
+
+	if charset == nil {
+		return CHARSET_DEFAULT
+	}
+
+	return charset.Name
+}
+
+// control codes kept by every charset, the protocol needs them
+func controlTable() (table [256]rune) {
+
+	table['\t'] = '\t'
+	table['\n'] = '\n'
+	table['\r'] = '\r'
+
+	return table
+}
+
+func asciiTable() [256]rune {
+
+	table := controlTable()
+
+	for b := 0x20; b < 0x7f; b++ {
+		table[b] = rune(b)
+	}
+
+	return table
+}
+
+// Atari 8 bit. 0x80-0xff are the same glyphs in inverse video.
+func atasciiTable() [256]rune {
+
+	table := asciiTable()
+
+	graphics := []rune("♥┣┃┛┫┓╱╲◢▗◣▝▘▔▂▖♣┏━╋●▄▎┳┻▌┗") // 0x00-0x1a
+
+	for b, r := range graphics {
+		if table[b] == 0 {
+			table[b] = r
+		}
+	}
+
+	table[0x60] = '♦'
+	table[0x7b] = '♠'
+	table[0x7d] = 0 // clear screen
+	table[0x7e] = 0 // backspace
+
+	for b := 0x80; b < 0x100; b++ {
+		table[b] = table[b-0x80]
+	}
+
+	// glyphs whose normal video byte is kept as a protocol control code
+	table[0x89] = '▗'
+	table[0x8a] = '◣'
+	table[0x8d] = '▔'
+
+	table[0x9b] = '\n' // ATASCII end of line
+
+	return table
+}
+
+func atascii() *Charset {
+
+	charset := newCharset("atascii", atasciiTable())
+	charset.eol = 0x9b
+	charset.encode['\n'] = 0x9b // not 0x0a, the lowest byte decoding to \n
+
+	return charset
+}
+
+// Commodore 8 bit, in the lowercase/uppercase (shifted) mode used for text
+func petsciiTable() [256]rune {
+
+	table := controlTable()
+
+	for b := 0x20; b < 0x40; b++ {
+		table[b] = rune(b)
+	}
+
+	table[0x40] = '@'
+
+	for b := 0; b < 26; b++ {
+		table[0x41+b] = rune('a' + b)
+		table[0xc1+b] = rune('A' + b)
+		table[0x61+b] = rune('A' + b) // shifted letters as typed on some machines
+	}
+
+	table[0x5b] = '['
+	table[0x5c] = '£'
+	table[0x5d] = ']'
+	table[0x5e] = '↑'
+	table[0x5f] = '←'
+
+	graphics := map[byte]rune{
+		0xa0: ' ', 0xa1: '▌', 0xa2: '▄', 0xa3: '▔', 0xa4: '▁', 0xa5: '▏', 0xa6: '▒', 0xa7: '▕',
+		0xa9: '◤', 0xab: '├', 0xac: '▗', 0xad: '└', 0xae: '┐', 0xaf: '▂',
+		0xb0: '┌', 0xb1: '┴', 0xb2: '┬', 0xb3: '┤', 0xb4: '▎', 0xb5: '▍', 0xb9: '▃',
+		0xba: '✓', 0xbb: '▖', 0xbc: '▝', 0xbd: '┘', 0xbe: '▘', 0xbf: '▚',
+		0xc0: '─', 0xdb: '┼', 0xdd: '│',
+	}
+
+	for b, r := range graphics {
+		table[b] = r
+	}
+
+	return table
+}
+
+func petscii() *Charset {
+
+	charset := newCharset("petscii", petsciiTable())
+
+	for b := 0; b < 26; b++ {
+		charset.encode[rune('A'+b)] = byte(0xc1 + b) // not the 0x61 duplicates
+	}
+
+	return charset
+}
+
+// IBM PC
+func cp437Table() [256]rune {
+
+	table := asciiTable()
+
+	table[0x7f] = '⌂'
+
+	high := []rune("ÇüéâäàåçêëèïîìÄÅ" +
+		"ÉæÆôöòûùÿÖÜ¢£¥₧ƒ" +
+		"áíóúñÑªº¿⌐¬½¼¡«»" +
+		"░▒▓│┤╡╢╖╕╣║╗╝╜╛┐" +
+		"└┴┬├─┼╞╟╚╔╩╦╠═╬╧" +
+		"╨╤╥╙╘╒╓╫╪┘┌█▄▌▐▀" +
+		"αßΓπΣσµτΦΘΩδ∞φε∩" +
+		"≡±≥≤⌠⌡÷≈°∙·√ⁿ²■ ")
+
+	for i, r := range high {
+		table[0x80+i] = r
+	}
+
+	return table
+}
+
+// closest glyphs of the unicode a charset lacks, tried in order
+var FOLD = map[rune]string{}
+
+func init() {
+
+	groups := []struct {
+		from string
+		to   string
+	}{
+		{"ÀÁÂÃÄÅĀĂĄ", "A"}, {"àáâãäåāăąª", "a"}, {"ÇĆĈĊČ", "C"}, {"çćĉċč¢", "c"},
+		{"ĎĐ", "D"}, {"ďđ", "d"}, {"ÈÉÊËĒĔĖĘĚ", "E"}, {"èéêëēĕėęě", "e"},
+		{"ĜĞĠĢ", "G"}, {"ĝğġģ", "g"}, {"ĤĦ", "H"}, {"ĥħ", "h"},
+		{"ÌÍÎÏĨĪĬĮİ", "I"}, {"ìíîïĩīĭįı", "i"}, {"Ĵ", "J"}, {"ĵ", "j"}, {"Ķ", "K"}, {"ķ", "k"},
+		{"ĹĻĽĿŁ", "L"}, {"ĺļľŀł", "l"}, {"ÑŃŅŇ", "N"}, {"ñńņňⁿ", "n"},
+		{"ÒÓÔÕÖØŌŎŐ", "O"}, {"òóôõöøōŏőº°", "o"}, {"ŔŖŘ", "R"}, {"ŕŗř", "r"},
+		{"ŚŜŞŠ", "S"}, {"śŝşš", "s"}, {"ŢŤŦ", "T"}, {"ţťŧ", "t"},
+		{"ÙÚÛÜŨŪŬŮŰŲ", "U"}, {"ùúûüũūŭůűųµ", "u"}, {"Ŵ", "W"}, {"ŵ", "w"},
+		{"ÝŶŸ", "Y"}, {"ýÿŷ¥", "y"}, {"ŹŻŽ", "Z"}, {"źżž", "z"},
+		{"Æ", "AE"}, {"æ", "ae"}, {"Œ", "OE"}, {"œ", "oe"}, {"ß", "ss"}, {"€", "EUR"}, {"£", "L"},
+		{"‘’‚′´", "'"}, {"“”„″«»", "\""}, {"–—―−", "-"}, {"…", "..."}, {"•·∙", "*"},
+		{"¡", "!"}, {"¿", "?"}, {"×", "x"}, {"÷", "/"}, {"±", "+-"}, {"©", "(c)"}, {"®", "(r)"},
+		{" ", " "}, {"✓", "v"}, {"♥♦♣♠", "*"}, {"●■", "o"},
+		{"━═", "─"}, {"┃║", "│"}, {"─", "-"}, {"│", "|"},
+		{"┏╔╒╓", "┌"}, {"┓╗╕╖", "┐"}, {"┗╚╘╙", "└"}, {"┛╝╛╜", "┘"},
+		{"┣╠╞╟", "├"}, {"┫╣╡╢", "┤"}, {"┳╦╤╥", "┬"}, {"┻╩╧╨", "┴"}, {"╋╬╪╫", "┼"},
+		{"┌┐└┘├┤┬┴┼", "+"}, {"░▒▓█▀▄▌▐", "#"},
+		{"↑", "^"}, {"↓", "v"}, {"←", "<-"}, {"→", "->"},
+		{"^", "↑"}, {"_", "▁"}, {"`", "'"}, {"{", "("}, {"}", ")"}, {"~", "-"}, {"|", "│"}, {"\\", "/"},
+	}
+
+	for _, group := range groups {
+		for _, r := range group.from {
+			FOLD[r] = group.to
+		}
+	}
+}
+
+// names of the charsets, sorted
+func charsetNames() []string {
+
+	names := make([]string, 0, len(CHARSETS))
+
+	for name := range CHARSETS {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// show or change the charset of the client
+func do_charset(clt *Client, args string) {
+
+	if no(args) {
+		clt.Say(">/charset>0>charset is %s (%s)", clt.Charset(), strings.Join(charsetNames(), ", "))
+
+		return
+	}
+
+	charset, ok := CHARSETS[strings.ToLower(args)]
+
+	if !ok {
+		clt.Say(">/charset>0>/charset <%s>", strings.Join(charsetNames(), "|"))
+
+		return
+	}
+
+	clt.charset.Store(charset)
+
+	clt.Say(">/charset>0>charset is now %s", charset)
+}
+
+// charset of the client, nil for utf8
+func (clt *Client) Charset() *Charset {
+	return clt.charset.Load()
+}
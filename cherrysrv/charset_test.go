@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCharset(t *testing.T) {
+
+	tests := []struct {
+		charset string
+		text    string // unicode
+		native  string // bytes for the client
+	}{
+		{"utf8", ">#main>@bob>canción\n", ">#main>@bob>canción\n"},
+		{"ascii", ">#main>@bob>canción “ok” …\n", ">#main>@bob>cancion \"ok\" ...\n"},
+		{"ascii", "╔═╗ ✗", "+-+ ?"},
+		{"cp437", "Ça va? ½ ╔═╗", "\x80a va? \xab \xc9\xcd\xbb"},
+		{"atascii", ">#main>@Bob>é ♥ ♦ {x}\n", ">#main>@Bob>e \x00 \x60 (x)\x9b"},
+		{"atascii", "┏━┓ ◣", "\x11\x12\x05 \x8a"},
+		{"petscii", ">#main>@Bob>Hi £5 ─│\n", ">#\x4d\x41\x49\x4e>@\xc2\x4f\x42>\xc8\x49 \x5c5 \xc0\xdd\n"},
+		{"petscii", "a_b|c", "\x41\xa4\x42\xdd\x43"},
+	}
+
+	for _, test := range tests {
+		charset := CHARSETS[test.charset]
+
+		if got := charset.Encode(test.text); got != test.native {
+			t.Errorf("%s.Encode(%q) = %q, want %q", test.charset, test.text, got, test.native)
+		}
+	}
+
+	decodes := []struct {
+		charset string
+		native  string
+		text    string
+	}{
+		{"utf8", "/login @bob", "/login @bob"},
+		{"utf8", "hi\r>#main>!admin>", "hi>#main>!admin>"}, // ends of line can't fake lines
+		{"ascii", "hi\x80\x01!\n", "hi!"},
+		{"cp437", "\x87a va", "ça va"},
+		{"atascii", "\x10 \x7b", "♣ ♠"},
+		{"atascii", "hi\x9b>#main>!admin>", "hi>#main>!admin>"},
+		{"atascii", "ab\x7e\x1cc", "abc"}, // backspace and cursor up are dropped
+		{"petscii", "\x2f\x4c\x4f\x47\x49\x4e \x40\xc2\x4f\x42\r", "/login @Bob"},
+		{"petscii", "\x61\x05\x5c", "A£"},
+	}
+
+	for _, test := range decodes {
+		charset := CHARSETS[test.charset]
+
+		if got := charset.Decode(test.native); got != test.text {
+			t.Errorf("%s.Decode(%q) = %q, want %q", test.charset, test.native, got, test.text)
+		}
+	}
+
+	// every decoded glyph encodes back to a byte decoding to the same glyph
+	for name, charset := range CHARSETS {
+		if charset == nil {
+			continue
+		}
+
+		for b, r := range charset.decode {
+			if r == 0 {
+				continue
+			}
+
+			if back := charset.decode[charset.Encode(string(r))[0]]; back != r {
+				t.Errorf("%s: byte %#x decodes to %q but it encodes back as %q", name, b, r, back)
+			}
+		}
+	}
+}
+
+func TestShorten(t *testing.T) {
+
+	a := func(n int) string { return strings.Repeat("a", n) }
+
+	tests := []struct {
+		name    string
+		caps    Caps
+		charset string
+		line    string
+		want    string // bytes sent
+	}{
+		{"fits", DEFAULT_CAPS, "utf8", a(254) + "\n", a(254) + "\n"},
+		{"utf8 char", DEFAULT_CAPS, "utf8", a(253) + "ééé\n", a(253) + "\n"},
+		{"folded glyphs", DEFAULT_CAPS, "ascii", strings.Repeat("©", 100) + "\n", strings.Repeat("(c)", 84) + "(c\n"},
+		{"crlf", Caps{EOL: "\r\n", MaxLine: MAX_LINE}, "utf8", a(254) + "\n", a(253) + "\r\n"},
+		{"atascii", DEFAULT_CAPS, "atascii", a(300) + "\n", a(254) + "\x9b"},
+	}
+
+	for _, test := range tests {
+		clt := &Client{}
+		clt.caps.Store(&test.caps)
+		clt.charset.Store(CHARSETS[test.charset])
+
+		if got := string(clt.shorten(test.line)); got != test.want {
+			t.Errorf("%s: shorten() = %q (%d bytes), want %q", test.name, got, len(got), test.want)
+		}
+	}
+}
+
+// an ATASCII end of line inside a /msg can't forge a line for the target
+func TestATASCIIForgery(t *testing.T) {
+
+	if _, ok := CHANNELS.Load("#main"); !ok {
+		main_channel := NewChannelMain("#main")
+		CHANNELS.Store(main_channel.Key(), main_channel)
+	}
+
+	// read the lines sent to a client as they come, ended by \n or by the
+	// ATASCII end of line once /charset atascii is set
+	lines := func(out net.Conn, in *bufio.Reader) chan string {
+		received := make(chan string, 100)
+
+		go func() {
+			var line []byte
+
+			for {
+				b, err := in.ReadByte()
+
+				switch {
+				case err != nil:
+					close(received)
+					return
+				case b == '\n' || b == 0x9b:
+					received <- string(line)
+					line = nil
+				default:
+					line = append(line, b)
+				}
+			}
+		}()
+
+		return received
+	}
+
+	// wait for a line containing text
+	expect := func(received chan string, text string) string {
+		t.Helper()
+
+		timeout := time.After(2 * time.Second)
+
+		for {
+			select {
+			case line := <-received:
+				if strings.Contains(line, text) {
+					return line
+				}
+			case <-timeout:
+				t.Fatalf("no line with %q", text)
+			}
+		}
+	}
+
+	_, victimOut, victimIn := genClient()
+	victim := lines(victimOut, victimIn)
+
+	_, evilOut, evilIn := genClient()
+	evil := lines(evilOut, evilIn)
+
+	defer func() {
+		victimOut.Close()
+		evilOut.Close()
+		waitDisconnected(t, "@victim")
+		waitDisconnected(t, "@evil")
+	}()
+
+	victimOut.Write([]byte("/login @victim\n"))
+	expect(victim, "you're now @victim")
+
+	evilOut.Write([]byte("/login @evil\n"))
+	expect(evil, "you're now @evil")
+
+	evilOut.Write([]byte("/charset atascii\n"))
+	expect(evil, "charset is now atascii")
+
+	evilOut.Write([]byte("/msg @victim hi\x9b>#main>!admin>@evil is root\x9b"))
+	expect(evil, "message sent to @victim")
+
+	if line := expect(victim, "@evil>@victim>"); line != ">@evil>@victim>hi" {
+		t.Errorf("@victim got %q, want the message up to the ATASCII end of line", line)
+	}
+
+	// the rest was a line of its own, said by @evil to nobody
+	expect(evil, "is not a valid channel")
+
+	select {
+	case line := <-victim:
+		if strings.Contains(line, "!admin") {
+			t.Errorf("@victim got a forged line %q", line)
+		}
+	case <-time.After(100 * time.Millisecond):
+	}
+}
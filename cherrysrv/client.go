@@ -1,11 +1,12 @@
 package main
 
 import (
-	"bufio"
+	"errors"
 	"fmt"
 	"net"
 	"strings"
 	"sync/atomic"
+	"time"
 )
 
 // client status
@@ -17,9 +18,19 @@ const (
 
 // Client connection storing basic client data
 type Client struct {
-	conn   net.Conn // network connection interface.
-	Name   string   // Name of the user.
+	conn   net.Conn    // network connection interface.
+	reader *LineReader // lines read from conn, only used in clientLoop
+	Name   string      // Name of the user.
 	Status atomic.Int32
+	Role   atomic.Int32  // ROLE_USER, ROLE_ADMIN
+	flood  *FloodControl // only used in clientLoop
+
+	connected  time.Time    // when the client connected
+	lastActive atomic.Int64 // unix nano time of the last line received
+	away       atomic.Value // string, reason given in /away
+
+	charset atomic.Pointer[Charset] // translation of what is read/written, nil for utf8
+	caps    atomic.Pointer[Caps]    // declared with /caps, nil for DEFAULT_CAPS
 }
 
 func (c *Client) String() string {
@@ -29,10 +40,15 @@ func (c *Client) String() string {
 func newClient(conn net.Conn) *Client {
 
 	client := &Client{
-		conn: conn,
-		Name: gensym("@Anon"),
+		conn:      conn,
+		reader:    newLineReader(conn),
+		Name:      gensym("@Anon"),
+		flood:     newFloodControl(config().Flood, time.Now()),
+		connected: time.Now(),
 	}
 	client.Status.Store(USER_NOTLOGGED)
+	client.Role.Store(ROLE_USER)
+	client.touch()
 
 	INFO.Printf("%s has connected (%s)", client.Name, client.conn.RemoteAddr())
 
@@ -48,43 +64,87 @@ func (c *Client) Key() string {
 // Close a client connection following ws protocol plus removing the internal handlers in the mud.
 func (clt *Client) Close() {
 
+	if !strings.HasPrefix(clt.Name, "@Anon-") { // guests never logged in, '-' is not valid in @names
+		audit("logout", clt.Name, "", "", "", "")
+	}
+
 	clt.RemoveMeFromAllChannels()
 	clt.conn.Close()
 	CLIENTS.Delete(clt.Name)
 }
 
 // main client loop that process client's messages
-// https://github.com/uber-go/ratelimit
 func (clt *Client) clientLoop() {
 
 	clt.Say(">#main>!welcome>welcome to cherry server %s # %s", clt.Name, STRINGVER)
 
+	for _, line := range config().MOTD {
+		clt.Say(">#main>!motd>%s", line)
+	}
+
 	for {
 
-		// we don't want to read from a socket that is logging out
+		// we don't want to read from a socket that is logging out (/kill)
 		if clt.Status.Load() == USER_LOGGINOUT {
+			clt.Close()
+
 			return
 		}
 
+		clt.setIdleDeadline()
+
 		line, err := clt.read()
+
+		if errors.Is(err, ErrLineTooLong) {
+			DEBUG.Printf("%s sent a line too long, cut to %q", clt, line)
+			err = nil
+		}
+
+		if err != nil && isTimeout(err) {
+			INFO.Printf("%s idle for %s, disconnecting (%s)", clt, config().Idle.Timeout, clt.conn.RemoteAddr())
+			clt.Say(">#main>!idle>disconnected after %s without activity", config().Idle.Timeout)
+			clt.UpdateInMain(">!disconnect>%s disconnected (idle)", clt)
+			clt.Close()
+
+			return
+		}
+
 		if err != nil {
 			INFO.Printf("%s disconnected (%s)", clt, clt.conn.RemoteAddr())
-			clt.UpdateInMain(">!disconnect>%s disconnected", clt)
+
+			if clt.Status.Load() != USER_LOGGINOUT { // a /kill has already told everyone
+				clt.UpdateInMain(">!disconnect>%s disconnected", clt)
+			}
+
 			clt.Close()
 
 			return
 		}
 
+		clt.touch()
+
 		command, args := parse(line)
 
 		if no(command) { // line was empty
 			continue
 		}
 
+		switch clt.flood.Check(clt, time.Now()) {
+		case FLOOD_DROP:
+			continue
+		case FLOOD_KICK:
+			WARN.Printf("%s disconnected for flooding (%s)", clt, clt.conn.RemoteAddr())
+			clt.Status.Store(USER_LOGGINOUT)
+			clt.UpdateInMain(">!flood>%s disconnected for flooding", clt)
+			clt.Close()
+
+			return
+		}
+
 		command, err = exec(clt, command, args)
 
 		if err != nil {
-			clt.Say(">/%s>0>command %s does not exist", command, command)
+			clt.Say("%s", replyLine(command, 0, "command "+command+" does not exist"))
 
 			continue // no really needed, but for consistency.
 		}
@@ -99,7 +159,7 @@ func (clt *Client) Say(format string, args ...interface{}) {
 	clt.write(line + "\n")
 }
 
-// Send len(Lines) with a lead message to the client
+// Send len(Lines) with a lead message to the client, each of them on a single line
 func (clt *Client) SayN(lead string, Lines []string) {
 
 	NumElems := len(Lines)
@@ -108,42 +168,34 @@ func (clt *Client) SayN(lead string, Lines []string) {
 		return
 	}
 
-	var output strings.Builder
+	var output []byte
 	NumElems -= 1 // we count from NumElems-1 to 0
 
 	for _, line := range Lines {
-		text := fmt.Sprintf("%s%d>%s\n", lead, NumElems, line)
+		text := fmt.Sprintf("%s%d>%s\n", lead, NumElems, oneLine(line))
 
-		text = shorten255(text)
-
-		output.WriteString(text)
+		output = append(output, clt.shorten(text)...)
 		NumElems -= 1
 	}
 
-	clt.writeNoLimit(output.String())
+	clt.writeNoLimit(output)
 
 }
 
-// write a message to the client. Limited to 255 chars.
+// write a message to the client. Limited to 255 chars (or the maxline of its caps).
 func (clt *Client) write(line string) (n int, err error) {
 
 	if len(line) == 0 {
 		return
 	}
 
-	line = shorten255(line)
-
-	return clt.writeNoLimit(line)
+	return clt.writeNoLimit(clt.shorten(line))
 }
 
-// writeNoLimit a message to the client. Unlimited length.
-func (clt *Client) writeNoLimit(line string) (n int, err error) {
-
-	if len(line) == 0 {
-		return
-	}
+// writeNoLimit bytes already encoded for the client. Unlimited length.
+func (clt *Client) writeNoLimit(data []byte) (n int, err error) {
 
-	DataLength, err := clt.conn.Write([]byte(line))
+	DataLength, err := clt.conn.Write(data)
 
 	if err != nil {
 		DEBUG.Printf("%s.write() failed with err: %s", clt, err)
@@ -152,23 +204,36 @@ func (clt *Client) writeNoLimit(line string) (n int, err error) {
 	return DataLength, err
 }
 
+// find a logged client by @name
+func findLoggedClient(name string) (*Client, bool) {
+
+	client, ok := CLIENTS.Load(name)
+
+	if !ok || !client.isLogged() {
+		return nil, false
+	}
+
+	return client, true
+}
+
 // check if client is logged
 func (clt *Client) isLogged() bool {
 	return clt.Status.Load() == USER_LOGGED
 }
 
-// Read message sent by client, limited to 255 chars
+// Read message sent by client, without the \n. Limited to 255 chars (or the
+// maxline of its caps), longer lines are cut returning ErrLineTooLong.
 func (client *Client) read() (string, error) {
 
-	netData, err := bufio.NewReader(client.conn).ReadString('\n')
+	charset := client.Charset()
 
-	if err != nil {
+	line, err := client.reader.ReadLine(client.Caps().MaxLine, charset.EOL())
+
+	if err != nil && err != ErrLineTooLong {
 		DEBUG.Printf("%s.read() failed with err: %s", client, err)
 	}
 
-	netData = shorten255(netData)
-
-	return netData, nil
+	return charset.Decode(line), err
 }
 
 // to be used by the server, send a message to everyone connected (including the sender)
@@ -179,7 +244,7 @@ func Broadcast(format string, args ...interface{}) {
 	line := fmt.Sprintf(format, args...)
 
 	broadcast := func(key string, clt *Client) bool {
-		clt.Say(line + "\n")
+		clt.Say("%s", line)
 		return true
 	}
 
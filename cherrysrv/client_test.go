@@ -26,8 +26,9 @@ func genClient() (c *Client, out net.Conn, in *bufio.Reader) {
 // TestClient is a set of ordered happy path tests
 func TestSingleClient(t *testing.T) {
 	// configure test server
-	init_logger()
-	init_commands()
+	setConfig(t, func(cfg *Config) {
+		cfg.Flood.Rate = 100 // this test is a script, it sends faster than any human
+	})
 	main_channel := NewChannelMain("#main")
 	CHANNELS.Store(main_channel.Key(), main_channel)
 
@@ -52,19 +53,40 @@ func TestSingleClient(t *testing.T) {
 		{"Duplicate Login Test", []byte("/login @tester2\n"), []string{">/login>0>you're already logged in"}},
 		{"User Count Test", []byte("/nusers\n"), []string{">/nusers>0>1"}},
 		{"User List Test", []byte("/users\n"), []string{">/users>0>@tester"}},
+		{"Admin Command Refused Test", []byte("/shutdown 1\n"), []string{">/shutdown>0>/shutdown requires admin privileges"}},
+		{"Admin Password Test", []byte("/admin secret\n"), []string{">/admin>0>wrong admin password"}},
+		{"Away Test", []byte("/away lunch\n"), []string{">/away>0>you're away: lunch"}},
+		{"Away User List Test", []byte("/users\n"), []string{">/users>0>@tester (away: lunch)"}},
+		{"Back Test", []byte("/back\n"), []string{">/back>0>welcome back @tester"}},
+		{"Mail Guest Test", []byte("/mail\n"), []string{">/mail>0>/mail requires you to be logged with a registered @nick"}},
+		{"Setinfo Guest Test", []byte("/setinfo bio hi\n"), []string{">/setinfo>0>/setinfo requires you to be logged with a registered @nick"}},
+		{"Pong Test", []byte("/pong 2023-01-01T00:00:00Z\n"), nil},
+		{"Private Message Offline Test", []byte("/msg @nobody hello\n"), []string{">/msg>0>@nobody is not online"}},
+		{"Private Message Test", []byte(fmt.Sprintf("/msg %s hello\n", username)), []string{fmt.Sprintf(">%s>%s>hello", username, username), fmt.Sprintf(">/msg>0>message sent to %s", username)}},
 		{"Channel Join Help Test", []byte("/join\n"), []string{">/join>0>/join <#channel>"}},
 		{"Channel Join Test", []byte(fmt.Sprintf("/join %s\n", chan1)), []string{fmt.Sprintf(">/join>0>%s joined %s", username, chan1)}},
+		{"Channel Topic Test", []byte(fmt.Sprintf("/topic %s retro chat\n", chan1)), []string{fmt.Sprintf(">%s>!topic>retro chat", chan1)}},
+		{"Channel Show Topic Test", []byte(fmt.Sprintf("/topic %s\n", chan1)), []string{fmt.Sprintf(">/topic>0>%s retro chat", chan1)}},
+		{"Not Operator Test", []byte("/mode #main +i\n"), []string{">/mode>0>you're not an operator of #main"}},
+		{"Kick Missing User Test", []byte(fmt.Sprintf("/kick %s @nobody\n", chan1)), []string{fmt.Sprintf(">/kick>0>@nobody is not in %s", chan1)}},
+		{"Ban Short Name Test", []byte(fmt.Sprintf("/ban %s @\n", chan1)), []string{">/ban>0>@ is not a valid username because username is too short"}},
+		{"Invite Short Name Test", []byte(fmt.Sprintf("/invite %s @\n", chan1)), []string{">/invite>0>@ is not a valid username because username is too short"}},
 		{"Channel Say Test", []byte(fmt.Sprintf("/say %s hello\n", chan1)), []string{fmt.Sprintf(">%s>%s>hello", chan1, username)}},
 		{"Channel Say Test #2", []byte(fmt.Sprintf("/say %s goodbye\n", chan1)), []string{fmt.Sprintf(">%s>%s>goodbye", chan1, username)}},
+		{"Channel History Test", []byte(fmt.Sprintf("/history %s 2\n", chan1)), []string{fmt.Sprintf(">/history %s>1>%s>hello", chan1, username), fmt.Sprintf(">/history %s>0>%s>goodbye", chan1, username)}},
 		{"Invalid Channel User Count Test", []byte("/nusers #bigapple\n"), []string{">/users #bigapple>0>#bigapple is not a valid channel"}},
 		{"Channel User Count Test", []byte(fmt.Sprintf("/nusers %s\n", chan1)), []string{fmt.Sprintf(">/users %s>0>1", chan1)}},
 		{"Channel User Test", []byte(fmt.Sprintf("/users %s\n", chan1)), []string{fmt.Sprintf(">/users %s>0>%s", chan1, username)}},
-		{"Channel List Test", []byte("/list\n"), []string{fmt.Sprintf(">/list>1>%s", main_channel.Name), fmt.Sprintf(">/list>0>%s", chan1)}},
+		{"Channel List Test", []byte("/list\n"), []string{fmt.Sprintf(">/list>1>%s", main_channel.Name), fmt.Sprintf(">/list>0>%s retro chat", chan1)}},
 		{"Channel Leave Test", []byte(fmt.Sprintf("/leave %s\n", chan1)), []string{fmt.Sprintf(">%s>%s>left the channel", chan1, username)}},
 		{"Channel List Test #2 (empty channel cleanup)", []byte("/list\n"), []string{fmt.Sprintf(">/list>0>%s", main_channel.Name)}},
 		{"Hidden Channel Join Test", []byte(fmt.Sprintf("/hjoin %s\n", chan2)), []string{fmt.Sprintf(">/hjoin>0>%s hjoined %s", username, chan2)}},
 		{"Channel List Test #3 (ignore hidden)", []byte("/list\n"), []string{fmt.Sprintf(">/list>0>%s", main_channel.Name)}},
 		{"Hidden Channel Leave Test", []byte(fmt.Sprintf("/leave %s\n", chan2)), []string{fmt.Sprintf(">%s>%s>left the channel", chan2, username)}},
+		{"Charset Test", []byte("/charset ascii\n"), []string{">/charset>0>charset is now ascii"}},
+		{"Caps Declare Test", []byte("/caps events=compact maxline=512\n"), []string{">/caps>0>client eol=lf maxline=512 events=compact charset=ascii"}},
+		{"Caps Unknown Test", []byte("/caps color=on\n"), []string{">/caps>0>unknown capability color"}},
+		{"Caps Wrong Value Test", []byte("/caps maxline=100\n"), []string{">/caps>0>maxline cannot be 100"}},
 		{"Logoff Test", []byte("/logoff\n"), []string{fmt.Sprintf(">/logoff>0>Goodbye %s", username)}},
 	}
 
@@ -105,3 +127,46 @@ func fullRead(buff *bufio.Reader, conn net.Conn, c chan []string) {
 	}
 	c <- s
 }
+
+// TestIdleTimeout checks idle clients are disconnected through the read deadline
+func TestIdleTimeout(t *testing.T) {
+
+	setConfig(t, func(cfg *Config) {
+		cfg.Idle.Timeout = 100 * time.Millisecond
+	})
+
+	c, out, in := genClient()
+
+	out.SetReadDeadline(time.Now().Add(2 * time.Second))
+
+	line, err := in.ReadString('\n')
+
+	if err != nil || line != fmt.Sprintf(">#main>!idle>disconnected after %s without activity\n", config().Idle.Timeout) {
+		t.Errorf("idle client got %q (%v)", line, err)
+	}
+
+	// wait for the server to close the connection
+	if _, err := in.ReadString('\n'); err == nil {
+		t.Errorf("idle client connection still open")
+	}
+
+	waitDisconnected(t, c.Name)
+}
+
+// waitDisconnected waits for the server to forget the client called name, so
+// that no goroutine of the client outlives the test
+func waitDisconnected(t *testing.T, name string) {
+	t.Helper()
+
+	for i := 0; ; i++ {
+		if _, ok := CLIENTS.Load(name); !ok {
+			break
+		}
+
+		if i == 100 {
+			t.Fatalf("%s is still in CLIENTS", name)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -3,15 +3,19 @@ package main
 import (
 	"runtime"
 	"sort"
+	"strconv"
+	"sync/atomic"
 )
 
 func init_commands() {
 	COMMANDS["login"] = do_login
 	COMMANDS["logoff"] = do_logoff
+	COMMANDS["register"] = do_register
 	COMMANDS["who"] = do_who
 	COMMANDS["users"] = do_users
 	COMMANDS["nusers"] = do_nusers
 	COMMANDS["say"] = do_say
+	COMMANDS["msg"] = do_msg
 	COMMANDS["clock"] = do_clock
 	COMMANDS["help"] = do_help
 	COMMANDS["version"] = do_version
@@ -20,26 +24,84 @@ func init_commands() {
 	COMMANDS["hjoin"] = do_hjoin
 	COMMANDS["leave"] = do_leave
 	COMMANDS["list"] = do_list
+	COMMANDS["history"] = do_history
 	COMMANDS["license"] = do_license
+	COMMANDS["topic"] = do_topic
+	COMMANDS["kick"] = do_kick
+	COMMANDS["ban"] = do_ban
+	COMMANDS["unban"] = do_unban
+	COMMANDS["op"] = do_op
+	COMMANDS["invite"] = do_invite
+	COMMANDS["mode"] = do_mode
+	COMMANDS["admin"] = do_admin
+	COMMANDS["pong"] = do_pong
+	COMMANDS["away"] = do_away
+	COMMANDS["back"] = do_back
+	COMMANDS["charset"] = do_charset
+	COMMANDS["caps"] = do_caps
+	COMMANDS["mail"] = do_mail
+	COMMANDS["whois"] = do_whois
+	COMMANDS["setinfo"] = do_setinfo
+
+	// admin commands
+	COMMANDS["log"] = sys_log
+	COMMANDS["kill"] = do_kill
+	COMMANDS["wall"] = do_wall
+	COMMANDS["shutdown"] = do_shutdown
+	COMMANDS["reload"] = do_reload
+
+	ROLES["log"] = ROLE_ADMIN
+	ROLES["kill"] = ROLE_ADMIN
+	ROLES["wall"] = ROLE_ADMIN
+	ROLES["shutdown"] = ROLE_ADMIN
+	ROLES["reload"] = ROLE_ADMIN
 }
 
 func do_help(clt *Client, args string) {
 
-	clt.SayN(">/help>",
-		[]string{"/login <nick> - login to cherry server",
-			"/who                       - show my nickname",
-			"/help                      - this command",
-			"/users                     - who is logged?",
-			"/users <#channel>          - who is in this channel?",
-			"/nusers                    - number of users",
-			"/nusers <#channel>         - number of users in channel",
-			"/list                      - show available public channels",
-			"/hlist                     - show available hidden channels",
-			"/join <#channel>           - join/create a channel",
-			"/hjoin <#channel>          - join/create hidden channel",
-			"/license                   - view license agreement",
-			"/logoff                    - logoff"})
-
+	help := []string{"/login <@nick> [password] - login to cherry server",
+		"/register <password>       - register your current @nick",
+		"/who                       - show my nickname",
+		"/whois <@nick>             - who is @nick?",
+		"/setinfo [key] [value]     - show/set your profile",
+		"/help                      - this command",
+		"/users                     - who is logged?",
+		"/users <#channel>          - who is in this channel?",
+		"/nusers                    - number of users",
+		"/nusers <#channel>         - number of users in channel",
+		"/list                      - show available public channels",
+		"/hlist                     - show available hidden channels",
+		"/msg <@nick> <text>        - private message to @nick",
+		"/mail [@nick text]         - list your mail / mail @nick",
+		"/mail <read|del> <n>       - read/delete mail n",
+		"/join <#channel>           - join/create a channel",
+		"/hjoin <#channel>          - join/create hidden channel",
+		"/history <#channel> [n]    - last n lines said in channel",
+		"/topic <#channel> [topic]  - show/set the channel topic",
+		"/kick <#channel> <@nick>   - (op) remove @nick from channel",
+		"/ban <#channel> <@nick>    - (op) kick and ban @nick",
+		"/unban <#channel> <@nick>  - (op) remove the ban of @nick",
+		"/op <#channel> <@nick>     - (op) make @nick an operator",
+		"/invite <#channel> <@nick> - (op) invite @nick to channel",
+		"/mode <#channel> <+i|-i>   - (op) invite only on/off",
+		"/charset [name]            - show/set your charset",
+		"/caps [key=value ...]      - show/declare capabilities",
+		"/license                   - view license agreement",
+		"/admin <password>          - get admin privileges",
+		"/logoff                    - logoff"}
+
+	if clt.isAdmin() {
+		help = append(help,
+			"/log [logger on|off]       - (admin) show/change loggers",
+			"/kill <@nick> [reason]     - (admin) disconnect @nick",
+			"/wall <text>               - (admin) message to everyone",
+			"/shutdown [minutes|cancel] - (admin) stop the server",
+			"/reload                    - (admin) reload configuration")
+	}
+
+	help = append(help, botHelp()...)
+
+	clt.SayN(">/help>", help)
 }
 
 func do_license(clt *Client, args string) {
@@ -61,7 +123,7 @@ func do_clock(clt *Client, args string) {
 		return
 	}
 
-	clt.Say(">/clock>0>%d", TIME)
+	clt.Say(">/clock>0>%d", atomic.LoadUint64(&TIME))
 }
 
 // show software version
@@ -160,7 +222,44 @@ func do_say(clt *Client, args string) {
 	channel.Say(clt, "%s", message)
 }
 
-// update login levels. Unused for now
+// talk privately to another logged user
+func do_msg(clt *Client, args string) {
+
+	if !clt.isLogged() {
+		clt.Say(">/msg>0>/msg requires you to be logged")
+
+		return
+	}
+
+	username, message := split2(args, " ")
+	message = trim(message)
+
+	if no(username) || no(message) {
+		clt.Say(">/msg>0>/msg <@nick> <text>")
+
+		return
+	}
+
+	target, ok := findLoggedClient(username)
+
+	if !ok {
+		clt.Say(">/msg>0>%s is not online", username)
+
+		return
+	}
+
+	target.Say("%s", messageLine(clt.Name, target.Name, message))
+	METRICS.Message()
+
+	if reason := target.Away(); !no(reason) {
+		clt.Say("%s", replyLine("msg", 0, "message sent to "+target.Name+" (away: "+reason+")"))
+		return
+	}
+
+	clt.Say(">/msg>0>message sent to %s", target)
+}
+
+// update login levels (admin only)
 func sys_log(clt *Client, args string) {
 
 	if no(args) {
@@ -216,7 +315,7 @@ func total_users(clt *Client) {
 	print_key := func(key string, c *Client) bool {
 
 		if c.Status.Load() != USER_LOGGINOUT {
-			out = append(out, key)
+			out = append(out, c.UserLine())
 		}
 		return true
 	}
@@ -236,11 +335,11 @@ func channel_users(clt *Client, channelName string) {
 		return
 	}
 
-	clt.SayN(">/users "+channel.Name+">", channel.ClientNames())
+	clt.SayN(">/users "+channel.Name+">", channel.UserLines())
 
 }
 
-// login user. No password required
+// login user. Registered users need a password, guests do not.
 func do_login(clt *Client, args string) {
 
 	/* Check params */
@@ -257,15 +356,33 @@ func do_login(clt *Client, args string) {
 		return
 	}
 
-	username, err := ValidUsername(args)
+	name, password := split2(args, " ")
+	password = trim(password)
+
+	username, err := ValidUsername(name)
 
 	if err != nil {
-		clt.Say(">/login>0>%s is not a valid username because %s", args, err.Error())
-		WARN.Printf("user %s unable to login due to: %s", args, err.Error())
+		clt.Say(">/login>0>%s is not a valid username because %s", name, err.Error())
+		WARN.Printf("user %s unable to login due to: %s", name, err.Error())
 
 		return
 	}
 
+	if accountStore().Exists(username) {
+
+		if no(password) {
+			clt.Say(">/login>0>%s is registered, use /login %s <password>", username, username)
+			return
+		}
+
+		if !accountStore().Check(username, password) {
+			clt.Say(">/login>0>wrong password for %s", username)
+			WARN.Printf("%s failed to login as %s (%s)", clt, username, clt.conn.RemoteAddr())
+
+			return
+		}
+	}
+
 	_, ok := CLIENTS.Load(username)
 
 	if ok {
@@ -291,9 +408,47 @@ func do_login(clt *Client, args string) {
 	clt.Say(">/login>0>you're now %s", clt)
 	clt.UpdateInMain(">!login>%s has joined the server", clt)
 
+	// only registered users can be admins by name, otherwise anyone could use it
+	if adminList().Contains(clt.Name) && accountStore().Exists(clt.Name) {
+		clt.grantAdmin()
+	}
+
+	rejoinChannels(clt)
+	announceMail(clt)
+
+	audit("login", clt.Name, "", "", "", clt.conn.RemoteAddr().String())
 	INFO.Printf("%s has logged in as %s", oldName, clt)
 }
 
+// register the current @nick with a password
+func do_register(clt *Client, args string) {
+
+	if !clt.isLogged() {
+		clt.Say(">/register>0>/register requires you to be logged")
+
+		return
+	}
+
+	if no(args) {
+		clt.Say(">/register>0>/register <password>")
+
+		return
+	}
+
+	// the password is the rest of the line, like in /login
+	password := trim(args)
+
+	if err := accountStore().Register(clt.Name, password); err != nil {
+		clt.Say(">/register>0>unable to register %s: %s", clt, err.Error())
+
+		return
+	}
+
+	clt.Say(">/register>0>%s is now registered, use /login %s <password> next time", clt, clt)
+
+	INFO.Printf("%s has registered", clt)
+}
+
 // logoff user
 func do_logoff(clt *Client, args string) {
 
@@ -334,8 +489,20 @@ func do_join(clt *Client, args string) {
 	channel, ok := CHANNELS.Load(channelName)
 
 	if ok {
+		if channel.contains(clt) {
+			clt.Say(">/join>0>you're already in %s", channel)
+			return
+		}
+
+		if err := channel.canJoin(clt); err != nil {
+			clt.Say(">/join>0>%s", err.Error())
+			return
+		}
+
 		if channel.addClient(clt) {
+			audit("join", clt.Name, channel.Name, "", "", "")
 			channel.Say(clt, "joined the channel")
+			sayTopic(clt, channel)
 			return
 		}
 
@@ -355,6 +522,8 @@ func do_join(clt *Client, args string) {
 
 	NewChannel := newChannel(channelName, false)
 	NewChannel.addClient(clt)
+	audit("join", clt.Name, NewChannel.Name, "", "", "")
+	NewChannel.setOp(clt) // the creator of the channel becomes its operator
 
 	CHANNELS.Store(NewChannel.Key(), NewChannel)
 	DEBUG.Printf("adding %s to CHANNELS", NewChannel)
@@ -381,8 +550,20 @@ func do_hjoin(clt *Client, args string) {
 	channel, ok := CHANNELS.Load(channelName)
 
 	if ok {
+		if channel.contains(clt) {
+			clt.Say(">/hjoin>0>you're already in %s", channel)
+			return
+		}
+
+		if err := channel.canJoin(clt); err != nil {
+			clt.Say(">/hjoin>0>%s", err.Error())
+			return
+		}
+
 		if channel.addClient(clt) {
+			audit("join", clt.Name, channel.Name, "", "", "")
 			channel.Say(clt, "hjoined the channel")
+			sayTopic(clt, channel)
 			return
 		}
 
@@ -402,6 +583,8 @@ func do_hjoin(clt *Client, args string) {
 
 	NewChannel := newChannel(channelName, true)
 	NewChannel.addClient(clt)
+	audit("join", clt.Name, NewChannel.Name, "", "", "")
+	NewChannel.setOp(clt) // the creator of the channel becomes its operator
 
 	CHANNELS.Store(NewChannel.Key(), NewChannel)
 	DEBUG.Printf("adding %s to CHANNELS", NewChannel)
@@ -437,6 +620,7 @@ func do_leave(clt *Client, args string) {
 
 		channel.Say(clt, "left the channel")
 		channel.removeClient(clt)
+		audit("leave", clt.Name, channel.Name, "", "", "")
 
 		return
 	}
@@ -458,9 +642,16 @@ func do_list(clt *Client, args string) {
 
 	print_key := func(key string, channel *Channel) bool {
 
-		if !channel.isHidden() {
-			out = append(out, key)
+		if channel.isHidden() {
+			return true
 		}
+
+		if topic := channel.Topic(); !no(topic) {
+			out = append(out, key+" "+topic)
+			return true
+		}
+
+		out = append(out, key)
 		return true
 	}
 
@@ -470,3 +661,48 @@ func do_list(clt *Client, args string) {
 
 	clt.SayN(">/list>", out)
 }
+
+// replay the last lines said in a channel
+func do_history(clt *Client, args string) {
+
+	if !clt.isLogged() {
+		clt.Say(">/history>0>/history requires you to be logged")
+
+		return
+	}
+
+	if no(args) {
+		clt.Say(">/history>0>/history <#channel> [n]")
+
+		return
+	}
+
+	channelName, number := split2(args, " ")
+
+	channel, ok := CHANNELS.Load(channelName)
+
+	if !ok {
+		clt.Say(">/history>0>%s is not a valid channel", channelName)
+		return
+	}
+
+	if !channel.contains(clt) {
+		clt.Say(">/history>0>you must /join %s before you can read it", channel.Name)
+		return
+	}
+
+	lines := HISTORY_DEFAULT
+
+	if !no(number) {
+		n, err := strconv.Atoi(trim(number))
+
+		if err != nil || n <= 0 {
+			clt.Say(">/history>0>%s is not a valid number of lines", trim(number))
+			return
+		}
+
+		lines = n
+	}
+
+	clt.SayN(">/history "+channel.Name+">", channel.History(lines))
+}
@@ -0,0 +1,518 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Config is the server configuration, read from an ini file:
+//
+//	; comments start with ';' or '#'
+//	[server]
+//	motd = first line of the message of the day
+//	motd = second line
+//	channels = #retro, #atari
+//
+// and overridden by the command line flags. SIGHUP (or /reload) reads it
+// again without dropping connections. Listeners only change on restart.
+type Config struct {
+
+	// [listeners]
+	TCP     string
+	Telnet  string
+	WS      string
+	TLS     string
+	TLSCert string
+	TLSKey  string
+	HTTP    string // admin port with /metrics, /audit and /game
+
+	// [server]
+	MOTD            []string
+	ReservedNames   []string
+	MaxNameLength   int
+	ShutdownMessage string
+	ShutdownGrace   time.Duration // countdown for the users when stopped by a signal
+	Snapshot        string        // file with the channels, saved on exit and restored on start
+	Channels        []string      // permanent channels created at startup
+	Accounts        string
+	Admins          string
+	AdminPass       string
+	HistoryDir      string
+	AuditLog        string
+	Mail            string
+	Profiles        string
+
+	Flood FloodLimits // [flood]
+	Idle  IdleLimits  // [idle]
+	IRC   IRCConfig   // [irc]
+	Bots  BotsConfig  // [bots]
+	Lobby LobbyConfig // [lobby]
+
+	Log map[string]string // [log] logger -> on/off
+}
+
+// ConfigKey is a value of the config file, optionally settable by a flag
+type ConfigKey struct {
+	section string
+	key     string
+	value   string // default value
+	flag    string // command line flag, empty if none
+	usage   string
+}
+
+const MAX_NAME_LENGTH = 16 // protocol limit, including '@'
+
+var CONFIG_KEYS = []ConfigKey{
+	{"listeners", "tcp", "", "srvaddr", "<address:port> for tcp4 server"},
+	{"listeners", "telnet", "", "telnetaddr", "<address:port> for telnet server"},
+	{"listeners", "websocket", "", "wsaddr", "<address:port> for websocket server"},
+	{"listeners", "tls", "", "tlsaddr", "<address:port> for tls server"},
+	{"listeners", "tls_cert", "", "tlscert", "<file> with the PEM certificate for the tls server"},
+	{"listeners", "tls_key", "", "tlskey", "<file> with the PEM private key for the tls server"},
+	{"listeners", "admin_http", "", "adminhttpaddr", "<address:port> for the http admin port with /metrics, /audit and /game (keep it private)"},
+
+	{"server", "motd", "", "", "line of the message of the day (repeat for more lines)"},
+	{"server", "reserved_names", "@srv", "", "@names nobody can use"},
+	{"server", "max_name_length", "16", "", "max length of @names"},
+	{"server", "shutdown_message", "Shutting down the server, it will re-start in a few minutes", "", "sent to everyone before shutting down"},
+	{"server", "shutdown_grace", "10s", "shutdowngrace", "time the users have to leave when the server is stopped by a signal"},
+	{"server", "snapshot", "", "snapshot", "<file> to save the channels on exit and restore them on start (empty disables it)"},
+	{"server", "channels", "", "", "permanent #channels created at startup"},
+	{"server", "accounts", "", "accounts", "<file> storing registered accounts (empty disables /register)"},
+	{"server", "admins", "", "admins", "<file> with the registered @nicks that are admins"},
+	{"server", "admin_password", "", "adminpass", "<bcrypt hash> of the /admin password (see bin/create_passwd)"},
+	{"server", "history_dir", "", "historydir", "<dir> to persist channel histories (empty keeps them in memory)"},
+	{"server", "mail", "", "mail", "<file> storing the /mail mailboxes (empty keeps them in memory)"},
+	{"server", "profiles", "", "profiles", "<file> storing the /setinfo profiles (empty keeps them in memory)"},
+	{"server", "audit_log", "", "auditlog", "<file> for the json lines audit log (empty keeps it in memory)"},
+
+	{"flood", "rate", "2", "floodrate", "lines per second a client can send in the long run"},
+	{"flood", "burst", "10", "floodburst", "lines a client can send at once"},
+	{"flood", "strikes", "3", "", "warnings before muting a flooding client"},
+	{"flood", "mute", "60s", "floodmute", "time a flooding client is muted"},
+	{"flood", "max_mutes", "3", "", "mutes before disconnecting a flooding client"},
+
+	{"idle", "ping", "2m", "pinginterval", "idle time before sending a !ping to a client (0 disables pings)"},
+	{"idle", "timeout", "60m", "idletimeout", "idle time before disconnecting a client (0 disables it)"},
+
+	{"irc", "server", "", "ircserver", "<address:port> of the irc server to bridge (empty disables the bridge)"},
+	{"irc", "nick", "cherry", "ircnick", "nick of the bridge in irc"},
+	{"irc", "password", "", "", "irc server password"},
+	{"irc", "channel", "#cherry", "ircchannel", "irc #channel bridged"},
+	{"irc", "bridge", "#irc", "ircbridge", "#channel bridged with irc"},
+
+	{"bots", "dice", "", "", "#channels where the @dice bot rolls dice (empty disables it)"},
+
+	{"lobby", "url", "", "lobbyurl", "<url> of the lobby /viewFull to open a room for every game server (empty disables it)"},
+	{"lobby", "poll", "30s", "", "time between reads of the lobby"},
+
+	{"log", "info", "", "", "on/off"},
+	{"log", "warn", "", "", "on/off"},
+	{"log", "error", "", "", "on/off"},
+	{"log", "debug", "", "", "on/off"},
+}
+
+var (
+	CONFIG     atomic.Pointer[Config]
+	CONFIGFILE string            // ini file, empty if none
+	FLAGS      map[string]string // flags set in the command line
+)
+
+func init() {
+	CONFIG.Store(defaultConfig())
+	ACCOUNTS.Store(newAccountStore(""))
+	ADMINS.Store(newAdminList("", ""))
+	MAIL.Store(newMailStore(""))
+	PROFILES.Store(newProfileStore(""))
+}
+
+// current configuration
+func config() *Config {
+	return CONFIG.Load()
+}
+
+// current accounts, replaced when the configuration is reloaded
+func accountStore() *AccountStore {
+	return ACCOUNTS.Load()
+}
+
+// current admins, replaced when the configuration is reloaded
+func adminList() *AdminList {
+	return ADMINS.Load()
+}
+
+// current mail, replaced when the configuration is reloaded
+func mailStore() *MailStore {
+	return MAIL.Load()
+}
+
+// current profiles, replaced when the configuration is reloaded
+func profileStore() *ProfileStore {
+	return PROFILES.Load()
+}
+
+func defaultConfig() *Config {
+
+	cfg := &Config{Log: make(map[string]string)}
+
+	for _, key := range CONFIG_KEYS {
+		if err := cfg.set(key.section, key.key, key.value); err != nil {
+			panic(err) // wrong default, a bug
+		}
+	}
+
+	return cfg
+}
+
+// register a command line flag for every ConfigKey with one
+func init_flags() {
+
+	for _, key := range CONFIG_KEYS {
+		if !no(key.flag) {
+			flag.String(key.flag, key.value, key.usage)
+		}
+	}
+
+	flag.StringVar(&CONFIGFILE, "config", "", "<file> ini config file (reloaded on SIGHUP)")
+}
+
+// remember the flags set in the command line, they override the config file
+func parse_flags() {
+
+	FLAGS = make(map[string]string)
+
+	flag.Visit(func(f *flag.Flag) {
+		FLAGS[f.Name] = f.Value.String()
+	})
+}
+
+// read the configuration: defaults, then the config file, then the flags
+func loadConfig() (*Config, error) {
+
+	cfg := defaultConfig()
+
+	if !no(CONFIGFILE) {
+		if err := cfg.load(CONFIGFILE); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, key := range CONFIG_KEYS {
+		if value, ok := FLAGS[key.flag]; ok && !no(key.flag) {
+			if err := cfg.set(key.section, key.key, value); err != nil {
+				return nil, fmt.Errorf("-%s: %s", key.flag, err)
+			}
+		}
+	}
+
+	return cfg, nil
+}
+
+// read an ini file on top of the current values
+func (cfg *Config) load(path string) error {
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	section := ""
+	scanner := bufio.NewScanner(file)
+
+	for lineno := 1; scanner.Scan(); lineno++ {
+		line := trim(scanner.Text())
+
+		if no(line) || line[0] == ';' || line[0] == '#' {
+			continue
+		}
+
+		if line[0] == '[' && line[len(line)-1] == ']' {
+			section = strings.ToLower(trim(line[1 : len(line)-1]))
+			continue
+		}
+
+		key, value := split2(line, "=")
+		key = strings.ToLower(trim(key))
+		value = trim(value)
+
+		if err := cfg.set(section, key, value); err != nil {
+			return fmt.Errorf("%s:%d %s", path, lineno, err)
+		}
+	}
+
+	return scanner.Err()
+}
+
+// split a comma separated list
+func splitList(value string) (list []string) {
+
+	for _, item := range strings.Split(value, ",") {
+		if item = trim(item); !no(item) {
+			list = append(list, item)
+		}
+	}
+
+	return list
+}
+
+// set a single value of the configuration
+func (cfg *Config) set(section string, key string, value string) (err error) {
+
+	switch section + "." + key {
+
+	case "listeners.tcp":
+		cfg.TCP = value
+	case "listeners.telnet":
+		cfg.Telnet = value
+	case "listeners.websocket":
+		cfg.WS = value
+	case "listeners.tls":
+		cfg.TLS = value
+	case "listeners.tls_cert":
+		cfg.TLSCert = value
+	case "listeners.tls_key":
+		cfg.TLSKey = value
+	case "listeners.admin_http":
+		cfg.HTTP = value
+
+	case "server.motd":
+		if !no(value) {
+			cfg.MOTD = append(cfg.MOTD, value)
+		}
+	case "server.reserved_names":
+		cfg.ReservedNames = splitList(value)
+	case "server.max_name_length":
+		cfg.MaxNameLength, err = strconv.Atoi(value)
+
+		if err == nil && (cfg.MaxNameLength < 2 || cfg.MaxNameLength > MAX_NAME_LENGTH) {
+			err = fmt.Errorf("max_name_length must be between 2 and %d", MAX_NAME_LENGTH)
+		}
+	case "server.shutdown_message":
+		cfg.ShutdownMessage = value
+	case "server.shutdown_grace":
+		cfg.ShutdownGrace, err = time.ParseDuration(value)
+	case "server.snapshot":
+		cfg.Snapshot = value
+	case "server.channels":
+		cfg.Channels = splitList(value)
+
+		for _, channelName := range cfg.Channels {
+			if _, err := ValidChannelname(channelName); err != nil {
+				return fmt.Errorf("%s is not a valid channel: %s", channelName, err)
+			}
+		}
+	case "server.accounts":
+		cfg.Accounts = value
+	case "server.admins":
+		cfg.Admins = value
+	case "server.admin_password":
+		cfg.AdminPass = value
+	case "server.history_dir":
+		cfg.HistoryDir = value
+	case "server.mail":
+		cfg.Mail = value
+	case "server.profiles":
+		cfg.Profiles = value
+	case "server.audit_log":
+		cfg.AuditLog = value
+
+	case "flood.rate":
+		cfg.Flood.Rate, err = strconv.ParseFloat(value, 64)
+	case "flood.burst":
+		cfg.Flood.Burst, err = strconv.ParseFloat(value, 64)
+	case "flood.strikes":
+		cfg.Flood.Strikes, err = strconv.Atoi(value)
+	case "flood.mute":
+		cfg.Flood.Mute, err = time.ParseDuration(value)
+	case "flood.max_mutes":
+		cfg.Flood.MaxMutes, err = strconv.Atoi(value)
+
+	case "idle.ping":
+		cfg.Idle.Ping, err = time.ParseDuration(value)
+	case "idle.timeout":
+		cfg.Idle.Timeout, err = time.ParseDuration(value)
+
+	case "irc.server":
+		cfg.IRC.Server = value
+	case "irc.nick":
+		cfg.IRC.Nick = value
+	case "irc.password":
+		cfg.IRC.Password = value
+	case "irc.channel":
+		cfg.IRC.Channel = value
+	case "irc.bridge":
+		cfg.IRC.Bridge = value
+
+		if _, err := ValidChannelname(value); err != nil {
+			return fmt.Errorf("%s is not a valid channel: %s", value, err)
+		}
+
+	case "bots.dice":
+		cfg.Bots.Dice = splitList(value)
+
+		for _, channelName := range cfg.Bots.Dice {
+			if _, err := ValidChannelname(channelName); err != nil {
+				return fmt.Errorf("%s is not a valid channel: %s", channelName, err)
+			}
+		}
+
+	case "lobby.url":
+		cfg.Lobby.URL = value
+	case "lobby.poll":
+		cfg.Lobby.Poll, err = time.ParseDuration(value)
+
+		if err == nil && cfg.Lobby.Poll < time.Second {
+			err = fmt.Errorf("must be 1s or more")
+		}
+
+	case "log.info", "log.warn", "log.error", "log.debug":
+		value = strings.ToLower(value)
+
+		if !no(value) && value != "on" && value != "off" {
+			return fmt.Errorf("%s must be on or off", key)
+		}
+
+		cfg.Log[key] = value
+
+	default:
+		return fmt.Errorf("unknown key '%s' in section [%s]", key, section)
+	}
+
+	if err != nil {
+		return fmt.Errorf("%s: %s", key, err)
+	}
+
+	return nil
+}
+
+// is name reserved by the configuration?
+func (cfg *Config) isReserved(name string) bool {
+
+	for _, reserved := range cfg.ReservedNames {
+		if strings.EqualFold(name, reserved) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// make cfg the current configuration, updating the subsystems that depend on it
+func applyConfig(cfg *Config) error {
+
+	accounts := newAccountStore(cfg.Accounts)
+
+	if err := accounts.Load(); err != nil {
+		return fmt.Errorf("unable to load accounts from %s (%s)", cfg.Accounts, err)
+	}
+
+	admins := newAdminList(cfg.Admins, cfg.AdminPass)
+
+	if err := admins.Load(); err != nil {
+		return fmt.Errorf("unable to load admins from %s (%s)", cfg.Admins, err)
+	}
+
+	if !no(cfg.HistoryDir) {
+		if err := os.MkdirAll(cfg.HistoryDir, 0700); err != nil {
+			return fmt.Errorf("unable to create history directory %s (%s)", cfg.HistoryDir, err)
+		}
+	}
+
+	mail := mailStore()
+
+	if mail.path != cfg.Mail { // reloading the same file would only lose the mails kept in memory
+		mail = newMailStore(cfg.Mail)
+
+		if err := mail.Load(); err != nil {
+			return fmt.Errorf("unable to load mail from %s (%s)", cfg.Mail, err)
+		}
+	}
+
+	profiles := profileStore()
+
+	if profiles.path != cfg.Profiles {
+		profiles = newProfileStore(cfg.Profiles)
+
+		if err := profiles.Load(); err != nil {
+			return fmt.Errorf("unable to load profiles from %s (%s)", cfg.Profiles, err)
+		}
+	}
+
+	if err := AUDIT.Open(cfg.AuditLog); err != nil {
+		return fmt.Errorf("unable to open audit log %s (%s)", cfg.AuditLog, err)
+	}
+
+	CONFIG.Store(cfg)
+	ACCOUNTS.Store(accounts)
+	ADMINS.Store(admins)
+	MAIL.Store(mail)
+	PROFILES.Store(profiles)
+
+	if !accounts.Enabled() {
+		WARN.Printf("No accounts file, /register is disabled and all users are guests")
+	}
+
+	for logger, onoff := range cfg.Log {
+		if !no(onoff) {
+			update_log_level(logger, onoff)
+		}
+	}
+
+	for _, channelName := range cfg.Channels {
+		if _, ok := CHANNELS.Load(channelName); ok {
+			continue
+		}
+
+		channel := NewChannelMain(channelName)
+		CHANNELS.Store(channel.Key(), channel)
+		DEBUG.Printf("adding %s to CHANNELS", channel)
+	}
+
+	return nil
+}
+
+// read the configuration again, keeping the current one if there's any error
+func reloadConfig() error {
+
+	cfg, err := loadConfig()
+
+	if err != nil {
+		ERROR.Printf("Unable to reload the configuration (%s)", err)
+		return err
+	}
+
+	old := config()
+
+	if cfg.TCP != old.TCP || cfg.Telnet != old.Telnet || cfg.WS != old.WS || cfg.TLS != old.TLS ||
+		cfg.TLSCert != old.TLSCert || cfg.TLSKey != old.TLSKey || cfg.HTTP != old.HTTP {
+		WARN.Printf("Listeners changed in the configuration, they will be updated on restart")
+	}
+
+	if cfg.IRC != old.IRC {
+		WARN.Printf("The irc bridge changed in the configuration, it will be updated on restart")
+	}
+
+	if cfg.Lobby != old.Lobby {
+		WARN.Printf("The lobby changed in the configuration, it will be updated on restart")
+	}
+
+	if err := applyConfig(cfg); err != nil {
+		ERROR.Printf("Unable to reload the configuration (%s)", err)
+		return err
+	}
+
+	if cfg.Idle.Ping != old.Idle.Ping {
+		schedulePing()
+	}
+
+	INFO.Printf("Configuration reloaded")
+
+	return nil
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// change the configuration for a test, restoring it when the test ends
+func setConfig(t *testing.T, change func(cfg *Config)) {
+
+	old := config()
+	cfg := *old
+	change(&cfg)
+
+	CONFIG.Store(&cfg)
+	t.Cleanup(func() { CONFIG.Store(old) })
+}
+
+func TestLoadConfig(t *testing.T) {
+
+	ini := `; cherry server
+[listeners]
+tcp = :1512
+
+[server]
+motd = Welcome to cherry!
+motd = Be nice
+reserved_names = @srv, @root
+channels = #retro, #atari
+
+[flood]
+rate = 0.5
+mute = 5m
+
+# idle settings
+[idle]
+timeout = 0
+
+[log]
+debug = off
+`
+	path := filepath.Join(t.TempDir(), "cherrysrv.ini")
+
+	if err := os.WriteFile(path, []byte(ini), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() { CONFIGFILE, FLAGS = "", nil }()
+
+	CONFIGFILE = path
+	FLAGS = map[string]string{"srvaddr": "localhost:1600", "floodburst": "4"}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		got  interface{}
+		want interface{}
+	}{
+		{"flag overrides file", cfg.TCP, "localhost:1600"},
+		{"repeated key", cfg.MOTD, []string{"Welcome to cherry!", "Be nice"}},
+		{"list", cfg.ReservedNames, []string{"@srv", "@root"}},
+		{"channels", cfg.Channels, []string{"#retro", "#atari"}},
+		{"float", cfg.Flood.Rate, 0.5},
+		{"flag", cfg.Flood.Burst, 4.0},
+		{"duration", cfg.Flood.Mute, 5 * time.Minute},
+		{"default", cfg.Flood.Strikes, 3},
+		{"zero duration", cfg.Idle.Timeout, time.Duration(0)},
+		{"default duration", cfg.Idle.Ping, 2 * time.Minute},
+		{"log", cfg.Log["debug"], "off"},
+		{"default name length", cfg.MaxNameLength, 16},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !reflect.DeepEqual(tt.got, tt.want) {
+				t.Errorf("got %v, want %v", tt.got, tt.want)
+			}
+		})
+	}
+
+	errors := []string{
+		"[server]\nunknown = 1\n",
+		"[flood]\nrate = fast\n",
+		"[server]\nchannels = #main\n",
+		"[server]\nmax_name_length = 17\n",
+		"[log]\ninfo = maybe\n",
+	}
+
+	for _, ini := range errors {
+		if err := defaultConfig().load(writeTemp(t, ini)); err == nil {
+			t.Errorf("load(%q) should fail", ini)
+		}
+	}
+}
+
+func TestReservedNames(t *testing.T) {
+
+	setConfig(t, func(cfg *Config) {
+		cfg.ReservedNames = []string{"@srv", "@root"}
+		cfg.MaxNameLength = 8
+	})
+
+	for _, name := range []string{"@root", "@ROOT", "@srv", "@a12345678"} {
+		if _, err := ValidUsername(name); err == nil {
+			t.Errorf("ValidUsername(%s) should fail", name)
+		}
+	}
+
+	if _, err := ValidUsername("@a123456"); err != nil {
+		t.Errorf("ValidUsername(@a123456) error = %v", err)
+	}
+}
+
+func writeTemp(t *testing.T, content string) string {
+
+	path := filepath.Join(t.TempDir(), "test.ini")
+
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
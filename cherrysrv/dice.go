@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+// DiceBot rolls dice with /roll <#channel> [NdM] or saying !roll [NdM] in
+// its channels (or to it with /msg)
+type DiceBot struct {
+	channels []string // channels to join
+}
+
+const (
+	DICE_MAX   = 20   // dice rolled at once
+	DICE_SIDES = 1000 // max sides of a die
+)
+
+func (dice *DiceBot) Name() string {
+	return "@dice"
+}
+
+func (dice *DiceBot) Start(bot *BotClient) error {
+
+	for _, channelName := range dice.channels {
+		if err := bot.Join(channelName); err != nil {
+			return fmt.Errorf("unable to join %s (%s)", channelName, err)
+		}
+	}
+
+	return bot.AddCommand("roll", "<#channel> [NdM] roll dice", func(clt *Client, args string) {
+
+		if !clt.isLogged() {
+			clt.Say(">/roll>0>/roll requires you to be logged")
+			return
+		}
+
+		channelName, spec := split2(args, " ")
+		channel, ok := CHANNELS.Load(channelName)
+
+		if !ok || !channel.contains(clt) || !channel.contains(bot.Client) {
+			clt.Say(">/roll>0>/roll <#channel> [NdM] in a channel with %s", bot)
+			return
+		}
+
+		result, err := roll(spec)
+
+		if err != nil {
+			clt.Say(">/roll>0>%s", err)
+			return
+		}
+
+		bot.Say(channel.Name, "%s rolls %s", clt, result)
+	})
+}
+
+func (dice *DiceBot) OnMessage(bot *BotClient, to string, from string, text string) {
+
+	command, spec := split2(trim(text), " ")
+
+	if command != "!roll" {
+		return
+	}
+
+	result, err := roll(spec)
+
+	if err != nil {
+		result = err.Error()
+	} else {
+		result = from + " rolls " + result
+	}
+
+	if to == bot.Name {
+		bot.Msg(from, "%s", result)
+		return
+	}
+
+	bot.Say(to, "%s", result)
+}
+
+// roll NdM dice (1d6 by default): "2d6: 3 + 5 = 8"
+func roll(spec string) (string, error) {
+
+	spec = strings.ToLower(trim(spec))
+
+	if no(spec) {
+		spec = "1d6"
+	}
+
+	number, sides := split2(spec, "d")
+
+	if no(number) {
+		number = "1"
+	}
+
+	n, err := strconv.Atoi(number)
+	m, err2 := strconv.Atoi(sides)
+
+	if err != nil || err2 != nil || n < 1 || n > DICE_MAX || m < 2 || m > DICE_SIDES {
+		return "", fmt.Errorf("dice are NdM, up to %dd%d", DICE_MAX, DICE_SIDES)
+	}
+
+	rolls := make([]string, n)
+	total := 0
+
+	for i := range rolls {
+		r := rand.Intn(m) + 1
+		rolls[i] = strconv.Itoa(r)
+		total += r
+	}
+
+	if n == 1 {
+		return fmt.Sprintf("%s: %d", spec, total), nil
+	}
+
+	return fmt.Sprintf("%s: %s = %d", spec, strings.Join(rolls, " + "), total), nil
+}
+
+// BotsConfig enables the bots that come with the server
+type BotsConfig struct {
+	Dice []string // channels of the dice bot
+}
+
+// start the bots enabled in the configuration
+func init_bots() {
+
+	if channels := config().Bots.Dice; len(channels) > 0 {
+		if _, err := startBot(&DiceBot{channels: channels}); err != nil {
+			ERROR.Printf("Unable to start the dice bot (%s)", err)
+		}
+	}
+
+	if lobby := config().Lobby; !no(lobby.URL) {
+		LOBBY = newLobbyBot(lobby)
+
+		if _, err := startBot(LOBBY); err != nil {
+			ERROR.Printf("Unable to start the lobby bot (%s)", err)
+			LOBBY = nil
+		}
+	}
+}
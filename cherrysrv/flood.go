@@ -0,0 +1,117 @@
+package main
+
+import (
+	"time"
+)
+
+// result of checking a line against the flood control
+const (
+	FLOOD_OK   = 1 // line can be processed
+	FLOOD_DROP = 2 // line is ignored
+	FLOOD_KICK = 3 // client must be disconnected
+)
+
+// FloodLimits configures the flood control of every client
+type FloodLimits struct {
+	Rate     float64       // lines per second allowed in the long run
+	Burst    float64       // lines that can be sent at once
+	Strikes  int           // warnings before being muted
+	Mute     time.Duration // time muted
+	MaxMutes int           // mutes before being disconnected
+}
+
+// TokenBucket allows Burst lines at once, refilled at Rate lines per second
+type TokenBucket struct {
+	tokens   float64
+	capacity float64
+	rate     float64
+	last     time.Time
+}
+
+func newTokenBucket(rate float64, burst float64, now time.Time) *TokenBucket {
+	return &TokenBucket{
+		tokens:   burst,
+		capacity: burst,
+		rate:     rate,
+		last:     now,
+	}
+}
+
+// take a token if there's one available
+func (bucket *TokenBucket) Take(now time.Time) bool {
+
+	bucket.tokens += now.Sub(bucket.last).Seconds() * bucket.rate
+	bucket.last = now
+
+	if bucket.tokens > bucket.capacity {
+		bucket.tokens = bucket.capacity
+	}
+
+	if bucket.tokens < 1 {
+		return false
+	}
+
+	bucket.tokens -= 1
+
+	return true
+}
+
+// FloodControl of a single client. It's only used from the client loop,
+// so it needs no locking.
+type FloodControl struct {
+	bucket     *TokenBucket
+	limits     FloodLimits
+	strikes    int
+	lastStrike time.Time
+	mutes      int
+	mutedUntil time.Time
+}
+
+func newFloodControl(limits FloodLimits, now time.Time) *FloodControl {
+	return &FloodControl{
+		bucket: newTokenBucket(limits.Rate, limits.Burst, now),
+		limits: limits,
+	}
+}
+
+// check a new line sent by the client at now, warning the client if needed
+func (flood *FloodControl) Check(clt *Client, now time.Time) int {
+
+	if now.Before(flood.mutedUntil) {
+		return FLOOD_DROP
+	}
+
+	// good behaviour for a while clears the warnings
+	if flood.strikes > 0 && now.Sub(flood.lastStrike) > flood.limits.Mute {
+		flood.strikes = 0
+	}
+
+	if flood.bucket.Take(now) {
+		return FLOOD_OK
+	}
+
+	flood.strikes++
+	flood.lastStrike = now
+
+	if flood.strikes < flood.limits.Strikes {
+		clt.Say(">#main>!flood>slow down %s, you're sending too fast (warning %d of %d)", clt, flood.strikes, flood.limits.Strikes)
+
+		return FLOOD_DROP
+	}
+
+	flood.strikes = 0
+	flood.mutes++
+
+	if flood.mutes >= flood.limits.MaxMutes {
+		clt.Say(">#main>!flood>%s disconnected for flooding", clt)
+
+		return FLOOD_KICK
+	}
+
+	flood.mutedUntil = now.Add(flood.limits.Mute)
+
+	clt.Say(">#main>!flood>%s muted for %s for flooding", clt, flood.limits.Mute)
+	WARN.Printf("%s muted for flooding (%s)", clt, clt.conn.RemoteAddr())
+
+	return FLOOD_DROP
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+
+	start := time.Now()
+	bucket := newTokenBucket(2, 3, start)
+
+	tests := []struct {
+		name  string
+		after time.Duration
+		want  bool
+	}{
+		{"burst 1", 0, true},
+		{"burst 2", 0, true},
+		{"burst 3", 0, true},
+		{"burst exhausted", 0, false},
+		{"half a token", 250 * time.Millisecond, false},
+		{"refilled 1 token", 500 * time.Millisecond, true},
+		{"no tokens again", 500 * time.Millisecond, false},
+		{"refill is capped to burst 1", 10 * time.Second, true},
+		{"refill is capped to burst 2", 10 * time.Second, true},
+		{"refill is capped to burst 3", 10 * time.Second, true},
+		{"refill is capped to burst 4", 10 * time.Second, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := bucket.Take(start.Add(tt.after)); got != tt.want {
+				t.Errorf("Take() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFloodControl(t *testing.T) {
+
+	server, out := net.Pipe()
+	defer out.Close()
+
+	go func() { // discard the warnings sent to the client
+		buffer := make([]byte, 1024)
+		for {
+			if _, err := out.Read(buffer); err != nil {
+				return
+			}
+		}
+	}()
+
+	clt := &Client{conn: server, Name: "@flooder"}
+	limits := FloodLimits{Rate: 1, Burst: 2, Strikes: 2, Mute: time.Minute, MaxMutes: 2}
+
+	now := time.Now()
+	flood := newFloodControl(limits, now)
+
+	expected := []int{
+		FLOOD_OK, FLOOD_OK, // burst
+		FLOOD_DROP, // warning
+		FLOOD_DROP, // muted
+		FLOOD_DROP, // still muted
+	}
+
+	for i, want := range expected {
+		if got := flood.Check(clt, now); got != want {
+			t.Errorf("line %d: Check() = %d, want %d", i, got, want)
+		}
+	}
+
+	// after the mute the bucket is full again, flooding once more disconnects
+
+	now = now.Add(limits.Mute + time.Second)
+
+	expected = []int{FLOOD_OK, FLOOD_OK, FLOOD_DROP, FLOOD_KICK}
+
+	for i, want := range expected {
+		if got := flood.Check(clt, now); got != want {
+			t.Errorf("line %d after mute: Check() = %d, want %d", i, got, want)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// recordConn keeps everything written to it
+type recordConn struct {
+	net.Conn
+	written bytes.Buffer
+}
+
+func (conn *recordConn) Write(p []byte) (int, error) {
+	return conn.written.Write(p)
+}
+
+func TestTelnetFilter(t *testing.T) {
+
+	IAC := string([]byte{TELNET_IAC})
+
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		replies string
+	}{
+		{"plain line", "/who\r\n", "/who\r\n", ""},
+		{"bare carriage return", "/who\r\x00", "/who\r\n", ""},
+		{"escaped IAC", "a" + IAC + IAC + "b\r\n", "a\xffb\r\n", ""},
+		{"linemode accepted", IAC + "\xfb\x22/who\r\n", "/who\r\n", IAC + "\xfa\x22\x01\x01" + IAC + "\xf0"},
+		{"echo refused", IAC + "\xfd\x01hi", "hi", IAC + "\xfc\x01"},
+		{"subnegotiation skipped", "a" + IAC + "\xfa\x22\x03\x01" + IAC + IAC + IAC + "\xf0b", "ab", ""},
+		{"ignored commands", IAC + "\xf1" + IAC + "\xfc\x01ok", "ok", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := &recordConn{}
+			telnet := &telnetConn{Conn: conn, state: TELNET_DATA}
+
+			// byte by byte must give the same result as all at once
+			var got []byte
+			for _, b := range []byte(tt.input) {
+				got = append(got, telnet.filter([]byte{b})...)
+			}
+
+			if string(got) != tt.want {
+				t.Errorf("filter(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+
+			if conn.written.String() != tt.replies {
+				t.Errorf("filter(%q) replied %q, want %q", tt.input, conn.written.String(), tt.replies)
+			}
+		})
+	}
+
+	conn := &recordConn{}
+	telnet := &telnetConn{Conn: conn}
+
+	telnet.Write([]byte("a\xffb\n"))
+
+	if got := conn.written.String(); got != "a\xff\xffb\r\n" {
+		t.Errorf("Write() sent %q", got)
+	}
+}
+
+func TestWebSocketClient(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(serveWS))
+	defer server.Close()
+
+	ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer ws.Close()
+
+	ws.SetReadDeadline(time.Now().Add(2 * time.Second))
+
+	_, welcome, err := ws.ReadMessage()
+	if err != nil || !strings.HasPrefix(string(welcome), ">#main>!welcome>") {
+		t.Fatalf("welcome message = %q (%v)", welcome, err)
+	}
+
+	ws.WriteMessage(websocket.TextMessage, []byte("/who"))
+
+	_, who, err := ws.ReadMessage()
+	if err != nil || !strings.HasPrefix(string(who), ">/who>0>@Anon-") {
+		t.Errorf("/who = %q (%v), want one message per line without \\n", who, err)
+	}
+
+	ws.WriteMessage(websocket.TextMessage, []byte("/help"))
+
+	_, help, err := ws.ReadMessage()
+	if err != nil || !strings.HasPrefix(string(help), ">/help>") || strings.Contains(string(help), "\n") {
+		t.Errorf("/help = %q (%v), want one message per line without \\n", help, err)
+	}
+
+	ws.Close()
+	waitDisconnected(t, strings.TrimPrefix(string(who), ">/who>0>"))
+}
+
+// create a self signed certificate for localhost, returning the cert and key files
+func genCertificate(t *testing.T) (certFile string, keyFile string) {
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile = filepath.Join(t.TempDir(), "cert.pem")
+	keyFile = filepath.Join(t.TempDir(), "key.pem")
+
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600)
+
+	return certFile, keyFile
+}
+
+func TestTLSClient(t *testing.T) {
+
+	certFile, keyFile := genCertificate(t)
+
+	server := listenTLS("127.0.0.1:0", certFile, keyFile)
+	defer server.Close()
+
+	go serve(server, nil)
+
+	conn, err := tls.Dial("tcp", server.Addr().String(), &tls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer conn.Close()
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	in := bufio.NewReader(conn)
+
+	if welcome, err := in.ReadString('\n'); err != nil || !strings.HasPrefix(welcome, ">#main>!welcome>") {
+		t.Fatalf("welcome message = %q (%v)", welcome, err)
+	}
+
+	conn.Write([]byte("/who\n"))
+
+	// skip events (as other clients disconnecting) until we get the reply
+	for {
+		line, err := in.ReadString('\n')
+
+		if err != nil {
+			t.Fatalf("/who got no reply (%v)", err)
+		}
+
+		if strings.HasPrefix(line, ">#main>!") {
+			continue
+		}
+
+		if !strings.HasPrefix(line, ">/who>0>@Anon-") {
+			t.Errorf("/who = %q", line)
+		}
+
+		conn.Close()
+		waitDisconnected(t, strings.TrimPrefix(trim(line), ">/who>0>"))
+
+		break
+	}
+}
@@ -8,4 +8,8 @@ require github.com/lrita/cmap v0.0.0-20220613164007-7fbf4a5bd437
 
 require github.com/madflojo/tasks v1.0.4
 
+require golang.org/x/crypto v0.9.0
+
+require github.com/gorilla/websocket v1.5.0
+
 require github.com/rs/xid v1.4.0 // indirect
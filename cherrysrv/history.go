@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	HISTORY_SIZE    = 50 // lines kept per channel
+	HISTORY_DEFAULT = 10 // lines replayed by /history when no number is given
+	HISTORY_COMPACT = 4  // the file is compacted when it holds HISTORY_COMPACT*HISTORY_SIZE lines
+)
+
+// History is a ring buffer with the last HISTORY_SIZE lines said in a channel
+type History struct {
+	lines      []string // ring buffer
+	next       int      // position where the next line will be stored
+	path       string   // file where lines are appended. Empty for memory only.
+	written    int      // lines in the file, compacted when too many
+	sync.Mutex          // for adding/reading lines
+}
+
+func newHistory(channelName string) *History {
+
+	history := &History{
+		lines: make([]string, 0, HISTORY_SIZE),
+		Mutex: sync.Mutex{},
+	}
+
+	historyDir := config().HistoryDir // empty keeps the history only in memory
+
+	if no(historyDir) {
+		return history
+	}
+
+	history.path = filepath.Join(historyDir, strings.TrimPrefix(channelName, "#")+".log")
+
+	history.load()
+
+	return history
+}
+
+// load the last HISTORY_SIZE lines from disk and compact the file to them
+func (h *History) load() {
+
+	file, err := os.Open(h.path)
+
+	if os.IsNotExist(err) {
+		return
+	}
+
+	if err != nil {
+		WARN.Printf("unable to read history %s (%s)", h.path, err)
+		return
+	}
+
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		h.store(scanner.Text())
+	}
+
+	file.Close()
+
+	h.compact()
+}
+
+// rewrite the file with the lines in the ring buffer only
+func (h *History) compact() {
+
+	lines := h.last(HISTORY_SIZE)
+
+	if err := os.WriteFile(h.path, []byte(strings.Join(lines, "\n")+"\n"), 0600); err != nil {
+		WARN.Printf("unable to compact history %s (%s)", h.path, err)
+		return
+	}
+
+	h.written = len(lines)
+}
+
+// store line in the ring buffer
+func (h *History) store(line string) {
+
+	if len(h.lines) < HISTORY_SIZE {
+		h.lines = append(h.lines, line)
+	} else {
+		h.lines[h.next] = line
+	}
+
+	h.next = (h.next + 1) % HISTORY_SIZE
+}
+
+// return up to the last n lines, oldest first
+func (h *History) last(n int) []string {
+
+	if n > len(h.lines) {
+		n = len(h.lines)
+	}
+
+	output := make([]string, 0, n)
+
+	for i := len(h.lines) - n; i < len(h.lines); i++ {
+
+		if len(h.lines) < HISTORY_SIZE {
+			output = append(output, h.lines[i])
+		} else {
+			output = append(output, h.lines[(h.next+i)%HISTORY_SIZE])
+		}
+	}
+
+	return output
+}
+
+// add a line to the history and, if persisted, to its file
+func (h *History) Add(line string) {
+	h.Lock()
+	defer h.Unlock()
+
+	h.store(line)
+
+	if no(h.path) {
+		return
+	}
+
+	if h.written >= HISTORY_COMPACT*HISTORY_SIZE {
+		h.compact()
+		return
+	}
+
+	file, err := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+
+	if err != nil {
+		WARN.Printf("unable to write history %s (%s)", h.path, err)
+		return
+	}
+
+	file.WriteString(line + "\n")
+	file.Close()
+
+	h.written++
+}
+
+// return up to the last n lines, oldest first
+func (h *History) Last(n int) []string {
+	h.Lock()
+	defer h.Unlock()
+
+	return h.last(n)
+}
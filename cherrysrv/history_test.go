@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHistory(t *testing.T) {
+
+	setConfig(t, func(cfg *Config) {
+		cfg.HistoryDir = t.TempDir()
+	})
+
+	history := newHistory("#retro")
+
+	for i := 0; i < HISTORY_SIZE+5; i++ {
+		history.Add(fmt.Sprintf("@tester>line %d", i))
+	}
+
+	last := fmt.Sprintf("@tester>line %d", HISTORY_SIZE+4)
+
+	tests := []struct {
+		name string
+		n    int
+		want []string
+	}{
+		{"last line", 1, []string{last}},
+		{"last 2 lines", 2, []string{fmt.Sprintf("@tester>line %d", HISTORY_SIZE+3), last}},
+		{"more than stored", HISTORY_SIZE * 2, history.Last(HISTORY_SIZE)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := history.Last(tt.n); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Last(%d) = %v, want %v", tt.n, got, tt.want)
+			}
+		})
+	}
+
+	if got := history.Last(HISTORY_SIZE); len(got) != HISTORY_SIZE || got[0] != "@tester>line 5" {
+		t.Errorf("ring buffer did not drop the oldest lines, got %v", got[0])
+	}
+
+	// a channel created again with the same name gets its history back from disk
+
+	reloaded := newHistory("#retro")
+
+	if !reflect.DeepEqual(reloaded.Last(HISTORY_SIZE), history.Last(HISTORY_SIZE)) {
+		t.Errorf("reloaded history = %v, want %v", reloaded.Last(HISTORY_SIZE), history.Last(HISTORY_SIZE))
+	}
+
+	// the file is compacted while lines are added, not only when loaded
+
+	for i := 0; i < HISTORY_COMPACT*HISTORY_SIZE; i++ {
+		history.Add(fmt.Sprintf("@tester>more %d", i))
+	}
+
+	data, err := os.ReadFile(history.path)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if lines := strings.Count(string(data), "\n"); lines > HISTORY_COMPACT*HISTORY_SIZE {
+		t.Errorf("history file has %d lines, want at most %d", lines, HISTORY_COMPACT*HISTORY_SIZE)
+	}
+
+	if reloaded := newHistory("#retro"); !reflect.DeepEqual(reloaded.Last(HISTORY_SIZE), history.Last(HISTORY_SIZE)) {
+		t.Errorf("reloaded history after compaction = %v, want %v", reloaded.Last(HISTORY_SIZE), history.Last(HISTORY_SIZE))
+	}
+}
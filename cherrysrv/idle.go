@@ -0,0 +1,150 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	"github.com/madflojo/tasks"
+)
+
+// IdleLimits configures how long a client can stay without sending anything
+type IdleLimits struct {
+	Ping    time.Duration // idle time before the server sends a !ping event
+	Timeout time.Duration // idle time before the client is disconnected. 0 disables it.
+}
+
+// set the read deadline of the client to the idle timeout
+func (clt *Client) setIdleDeadline() {
+
+	timeout := config().Idle.Timeout
+
+	if timeout <= 0 {
+		return
+	}
+
+	clt.conn.SetReadDeadline(time.Now().Add(timeout))
+}
+
+// true if err is a read deadline being reached
+func isTimeout(err error) bool {
+
+	var netErr net.Error
+
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// the client sent something
+func (clt *Client) touch() {
+	clt.lastActive.Store(time.Now().UnixNano())
+}
+
+// time since the client sent something
+func (clt *Client) idleTime() time.Duration {
+	return time.Since(time.Unix(0, clt.lastActive.Load()))
+}
+
+const PING_TASK = "ping" // id of pingIdleClients in the SCHEDULER
+
+// schedule pingIdleClients every Idle.Ping, replacing the previous schedule
+func schedulePing() {
+
+	if SCHEDULER == nil { // not started yet, init_scheduler will call us
+		return
+	}
+
+	SCHEDULER.Del(PING_TASK)
+
+	interval := config().Idle.Ping
+
+	if interval <= 0 {
+		return
+	}
+
+	if err := SCHEDULER.AddWithID(PING_TASK, &tasks.Task{Interval: interval, TaskFunc: pingIdleClients}); err != nil {
+		ERROR.Printf("unable to schedule the !ping of idle clients (%s)", err)
+	}
+}
+
+// send a !ping to the clients idle for more than Idle.Ping. Sending to a
+// dead connection fails, so ghost clients are closed here.
+func pingIdleClients() error {
+
+	interval := config().Idle.Ping
+
+	ping := func(key string, clt *Client) bool {
+
+		if clt.Status.Load() == USER_LOGGINOUT || clt.idleTime() < interval {
+			return true
+		}
+
+		if _, err := clt.write(">#main>!ping>" + time.Now().UTC().Format(time.RFC3339) + "\n"); err != nil {
+			INFO.Printf("%s does not answer, closing its connection (%s)", clt, err)
+			clt.conn.Close() // clientLoop will clean up the client
+		}
+
+		return true
+	}
+
+	CLIENTS.Range(ping)
+
+	return nil
+}
+
+// answer to a !ping. Any line resets the idle time, so there's nothing else to do.
+func do_pong(clt *Client, args string) {
+}
+
+// mark the client as away
+func do_away(clt *Client, args string) {
+
+	if !clt.isLogged() {
+		clt.Say(">/away>0>/away requires you to be logged")
+
+		return
+	}
+
+	if no(args) {
+		args = "away"
+	}
+
+	clt.away.Store(args)
+
+	clt.Say(">/away>0>you're away: %s", args)
+}
+
+// mark the client as back from away
+func do_back(clt *Client, args string) {
+
+	if !clt.isLogged() {
+		clt.Say(">/back>0>/back requires you to be logged")
+
+		return
+	}
+
+	clt.away.Store("")
+
+	clt.Say(">/back>0>welcome back %s", clt)
+}
+
+// reason given by the client in /away. Empty if not away.
+func (clt *Client) Away() string {
+
+	reason, _ := clt.away.Load().(string)
+
+	return reason
+}
+
+// name of the client as shown in /users
+func (clt *Client) UserLine() string {
+
+	if clt.isBot() {
+		return clt.Name + " (bot)"
+	}
+
+	if reason := clt.Away(); !no(reason) {
+		return clt.Name + " (away: " + reason + ")"
+	}
+
+	return clt.Name
+}
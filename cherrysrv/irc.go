@@ -0,0 +1,394 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// IRCConfig configures the bridge between a cherry channel and an irc channel
+type IRCConfig struct {
+	Server   string // irc server <address:port>. Empty disables the bridge.
+	Nick     string // nick of the bridge in irc
+	Password string // irc server password, if any
+	Channel  string // irc channel
+	Bridge   string // cherry channel
+}
+
+const (
+	IRC_RECONNECT_MIN = 5 * time.Second
+	IRC_RECONNECT_MAX = 5 * time.Minute
+	IRC_LISTENER      = "irc" // name of the bridge as a channel listener
+	IRC_RELAY_QUEUE   = 100   // lines said in cherry waiting to be sent to irc
+	IRC_LINE          = 510   // longest irc line, 512 with its \r\n
+)
+
+// IRCBridge connects as a client to an irc server and mirrors what is said
+// in the irc channel into the cherry channel, and the other way round.
+type IRCBridge struct {
+	config     IRCConfig
+	channel    *Channel
+	dial       func(address string) (net.Conn, error)
+	conn       net.Conn    // current irc connection, nil if disconnected
+	nick       string      // current nick, may differ from config.Nick if it was taken
+	relays     chan string // PRIVMSGs to send, so a slow irc server never blocks the cherry channel
+	sync.Mutex             // for writing to conn
+}
+
+func newIRCBridge(config IRCConfig) *IRCBridge {
+
+	channel, ok := CHANNELS.Load(config.Bridge)
+
+	if !ok {
+		channel = NewChannelMain(config.Bridge)
+		CHANNELS.Store(channel.Key(), channel)
+		DEBUG.Printf("adding %s to CHANNELS", channel)
+	}
+
+	bridge := &IRCBridge{
+		config:  config,
+		channel: channel,
+		dial: func(address string) (net.Conn, error) {
+			return net.DialTimeout("tcp", address, 30*time.Second)
+		},
+		relays: make(chan string, IRC_RELAY_QUEUE),
+		Mutex:  sync.Mutex{},
+	}
+
+	channel.Listen(IRC_LISTENER, bridge.relay)
+
+	go bridge.sendRelays()
+
+	return bridge
+}
+
+// keep the bridge connected forever
+func (bridge *IRCBridge) Run() {
+
+	wait := IRC_RECONNECT_MIN
+
+	for {
+		started := time.Now()
+
+		err := bridge.session()
+
+		WARN.Printf("irc bridge %s disconnected from %s (%s)", bridge.channel, bridge.config.Server, err)
+		bridge.channel.Event("irc", "bridge to %s disconnected", bridge.config.Channel)
+
+		if time.Since(started) > IRC_RECONNECT_MAX {
+			wait = IRC_RECONNECT_MIN // it was a long session, try again soon
+		}
+
+		time.Sleep(wait)
+
+		if wait *= 2; wait > IRC_RECONNECT_MAX {
+			wait = IRC_RECONNECT_MAX
+		}
+	}
+}
+
+// a single connection to the irc server, until it fails
+func (bridge *IRCBridge) session() error {
+
+	conn, err := bridge.dial(bridge.config.Server)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	bridge.Lock()
+	bridge.conn = conn
+	bridge.nick = bridge.config.Nick
+	bridge.Unlock()
+
+	defer func() {
+		bridge.Lock()
+		bridge.conn = nil
+		bridge.Unlock()
+	}()
+
+	if !no(bridge.config.Password) {
+		bridge.send("PASS %s", bridge.config.Password)
+	}
+
+	bridge.send("NICK %s", bridge.config.Nick)
+	bridge.send("USER %s 0 * :cherry server bridge", bridge.config.Nick)
+
+	scanner := bufio.NewScanner(conn)
+
+	for scanner.Scan() {
+		bridge.handle(scanner.Text())
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	return fmt.Errorf("connection closed")
+}
+
+// send a line to the irc server
+func (bridge *IRCBridge) send(format string, args ...interface{}) {
+	bridge.Lock()
+	defer bridge.Unlock()
+
+	if bridge.conn == nil {
+		return
+	}
+
+	line := fmt.Sprintf(format, args...)
+
+	if _, err := bridge.conn.Write([]byte(line + "\r\n")); err != nil {
+		DEBUG.Printf("irc bridge write failed with err: %s", err)
+	}
+}
+
+// IRCMessage is a parsed irc line: [:prefix] command params... [:trailing]
+type IRCMessage struct {
+	Nick    string // nick in the prefix, if any
+	Command string
+	Params  []string // trailing included as the last param
+}
+
+func parseIRC(line string) (msg IRCMessage) {
+
+	line = strings.TrimRight(line, "\r\n")
+
+	if strings.HasPrefix(line, ":") {
+		var prefix string
+		prefix, line = split2(line[1:], " ")
+		msg.Nick, _ = split2(prefix, "!")
+	}
+
+	var trailing string
+	hasTrailing := false
+
+	if i := strings.Index(line, " :"); i >= 0 {
+		line, trailing = line[:i], line[i+2:]
+		hasTrailing = true
+	} else if strings.HasPrefix(line, ":") {
+		line, trailing = "", line[1:]
+		hasTrailing = true
+	}
+
+	fields := strings.Fields(line)
+
+	if len(fields) > 0 {
+		msg.Command = strings.ToUpper(fields[0])
+		msg.Params = fields[1:]
+	}
+
+	if hasTrailing {
+		msg.Params = append(msg.Params, trailing)
+	}
+
+	return msg
+}
+
+// process a line received from the irc server
+func (bridge *IRCBridge) handle(line string) {
+
+	msg := parseIRC(line)
+
+	param := func(n int) string {
+		if n < len(msg.Params) {
+			return msg.Params[n]
+		}
+		return ""
+	}
+
+	switch msg.Command {
+
+	case "PING":
+		bridge.send("PONG :%s", param(0))
+
+	case "001": // registered in the server
+		bridge.send("JOIN %s", bridge.config.Channel)
+		INFO.Printf("irc bridge %s connected to %s %s", bridge.channel, bridge.config.Server, bridge.config.Channel)
+
+	case "433": // nick in use
+		bridge.Lock()
+		bridge.nick += "_"
+		nick := bridge.nick
+		bridge.Unlock()
+
+		bridge.send("NICK %s", nick)
+
+	case "PRIVMSG", "NOTICE":
+		if !strings.EqualFold(param(0), bridge.config.Channel) {
+			return // private messages to the bridge are ignored
+		}
+
+		text := param(1)
+
+		if strings.HasPrefix(text, "\x01ACTION ") {
+			text = "*" + strings.Trim(text[len("\x01ACTION "):], "\x01") + "*"
+		}
+
+		bridge.say(msg.Nick, text)
+
+	case "JOIN", "PART", "QUIT":
+		if msg.Nick == bridge.currentNick() {
+			return
+		}
+
+		verb := map[string]string{"JOIN": "joined", "PART": "left", "QUIT": "quit"}[msg.Command]
+
+		bridge.channel.Event("irc", "%s %s %s", ircUsername(msg.Nick), verb, bridge.config.Channel)
+	}
+}
+
+func (bridge *IRCBridge) currentNick() string {
+	bridge.Lock()
+	defer bridge.Unlock()
+
+	return bridge.nick
+}
+
+// say in the cherry channel a message from an irc nick, wrapped to the line limit
+func (bridge *IRCBridge) say(nick string, text string) {
+
+	from := ircUsername(nick)
+	text = stripIRCFormatting(text)
+
+	// >#channel>@nick>text\n must fit in 255 chars
+	width := 254 - len(">"+bridge.channel.Name+">"+from+">")
+
+	for _, line := range wrap(text, width) {
+		bridge.channel.SayAs(IRC_LISTENER, from, line)
+	}
+}
+
+// channel listener sending to irc what is said in the cherry channel
+func (bridge *IRCBridge) relay(channel *Channel, from string, message string) {
+
+	prefix := fmt.Sprintf("PRIVMSG %s :<%s> ", bridge.config.Channel, ircText(from))
+
+	for _, text := range wrap(ircText(message), IRC_LINE-len(prefix)) {
+		select {
+		case bridge.relays <- prefix + text:
+		default:
+			WARN.Printf("irc bridge %s is too slow, dropping %q", bridge.channel, text)
+		}
+	}
+}
+
+// send the relayed lines to irc, forever
+func (bridge *IRCBridge) sendRelays() {
+
+	for line := range bridge.relays {
+		bridge.send("%s", line)
+	}
+}
+
+// keep text on a single irc line: \r or \n would end the PRIVMSG and start
+// a new irc command, and NUL is not allowed
+func ircText(text string) string {
+	return strings.ReplaceAll(oneLine(text), "\x00", "")
+}
+
+// map an irc nick to an @username that passes ValidUsername and does not
+// impersonate a registered or connected cherry user
+func ircUsername(nick string) string {
+
+	var name strings.Builder
+
+	for _, r := range nick {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			name.WriteRune(r)
+		}
+	}
+
+	username := name.String()
+
+	if no(username) || isDigit(username[0]) {
+		username = "i" + username
+	}
+
+	username = "@" + username
+
+	maxLength := config().MaxNameLength
+
+	if len(username) > maxLength {
+		username = username[:maxLength]
+	}
+
+	taken := func(username string) bool {
+		_, connected := CLIENTS.Load(username)
+		_, err := ValidUsername(username)
+
+		return connected || err != nil || accountStore().Exists(username)
+	}
+
+	// irc users taking a cherry name get a number
+	for i := 1; taken(username) && i < 100; i++ {
+		suffix := fmt.Sprint(i)
+		base := username
+
+		if len(base)+len(suffix) > maxLength {
+			base = base[:maxLength-len(suffix)]
+		}
+
+		username = strings.TrimRight(base, "0123456789") + suffix
+
+		if len(username) > maxLength || len(username) < 2 || isDigit(username[1]) {
+			username = "@i" + suffix
+		}
+	}
+
+	return username
+}
+
+// remove the irc bold, color, italic... control codes
+func stripIRCFormatting(text string) string {
+
+	var output strings.Builder
+
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+
+		switch {
+		case c == 0x03: // color: \x03[fg[,bg]]
+			for n := 0; n < 2 && i+1 < len(text) && isDigit(text[i+1]); n++ {
+				i++
+			}
+
+			if i+2 < len(text) && text[i+1] == ',' && isDigit(text[i+2]) {
+				i += 2
+
+				if i+1 < len(text) && isDigit(text[i+1]) {
+					i++
+				}
+			}
+		case c < 0x20: // bold, italic, underline, reverse, reset...
+		default:
+			output.WriteByte(c)
+		}
+	}
+
+	return output.String()
+}
+
+// split text in lines of at most width bytes, breaking at spaces if possible
+func wrap(text string, width int) (lines []string) {
+
+	for len(text) > width {
+		cut := strings.LastIndex(text[:width+1], " ")
+
+		if cut <= 0 {
+			cut = len(cutRunes(text, width))
+		}
+
+		lines = append(lines, trim(text[:cut]))
+		text = trim(text[cut:])
+	}
+
+	if !no(text) {
+		lines = append(lines, text)
+	}
+
+	return lines
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bufio"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseIRC(t *testing.T) {
+
+	tests := []struct {
+		line string
+		want IRCMessage
+	}{
+		{"PING :irc.example.org", IRCMessage{"", "PING", []string{"irc.example.org"}}},
+		{":irc.example.org 001 cherry :Welcome", IRCMessage{"irc.example.org", "001", []string{"cherry", "Welcome"}}},
+		{":bob!b@host PRIVMSG #retro :hello there\r\n", IRCMessage{"bob", "PRIVMSG", []string{"#retro", "hello there"}}},
+		{":bob!b@host JOIN #retro", IRCMessage{"bob", "JOIN", []string{"#retro"}}},
+		{":bob!b@host QUIT :", IRCMessage{"bob", "QUIT", []string{""}}},
+	}
+
+	for _, test := range tests {
+		if got := parseIRC(test.line); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("parseIRC(%q) = %#v, want %#v", test.line, got, test.want)
+		}
+	}
+}
+
+func TestIRCText(t *testing.T) {
+
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"plain nick", ircUsername("bob"), "@bob"},
+		{"nick with symbols", ircUsername("[bob]_"), "@bob"},
+		{"nick with a digit first", ircUsername("8bit"), "@i8bit"},
+		{"long nick", ircUsername("averyveryverylongnick"), "@averyveryverylo"},
+		{"reserved nick", ircUsername("srv"), "@srv1"},
+		{"bold and color", stripIRCFormatting("\x02bold\x02 \x0304,12red\x03 plain"), "bold red plain"},
+		{"one irc line", ircText("hi\r\nQUIT :bye\x00\rPART"), "hi QUIT :bye PART"},
+	}
+
+	for _, test := range tests {
+		if test.got != test.want {
+			t.Errorf("%s: got %q, want %q", test.name, test.got, test.want)
+		}
+	}
+
+	lines := wrap("one two three four", 9)
+
+	if want := []string{"one two", "three", "four"}; !reflect.DeepEqual(lines, want) {
+		t.Errorf("wrap = %q, want %q", lines, want)
+	}
+
+	lines = wrap("ñandú", 3)
+
+	if want := []string{"ña", "nd", "ú"}; !reflect.DeepEqual(lines, want) {
+		t.Errorf("wrap = %q, want %q without split utf8 chars", lines, want)
+	}
+}
+
+func TestIRCBridge(t *testing.T) {
+
+	server, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	bridge := newIRCBridge(IRCConfig{
+		Server:  server.Addr().String(),
+		Nick:    "cherry",
+		Channel: "#retro",
+		Bridge:  "#irctest",
+	})
+	defer CHANNELS.Delete("#irctest")
+
+	go bridge.session()
+
+	conn, err := server.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(2 * time.Second))
+	reader := bufio.NewReader(conn)
+
+	expect := func(want string) {
+		t.Helper()
+
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				t.Fatalf("waiting for %q: %s", want, err)
+			}
+
+			if strings.TrimRight(line, "\r\n") == want {
+				return
+			}
+		}
+	}
+
+	expect("USER cherry 0 * :cherry server bridge")
+	conn.Write([]byte(":irc.example.org 001 cherry :Welcome\r\n"))
+	expect("JOIN #retro")
+
+	conn.Write([]byte(":bob!b@host PRIVMSG #retro :hello from irc\r\n"))
+	conn.Write([]byte("PING :irc.example.org\r\n"))
+	expect("PONG :irc.example.org")
+
+	if history := bridge.channel.History(1); !reflect.DeepEqual(history, []string{"@bob>hello from irc"}) {
+		t.Errorf("history = %q, want the irc message", history)
+	}
+
+	bridge.channel.SayAs("", "@alice", "hello from cherry")
+	expect("PRIVMSG #retro :<@alice> hello from cherry")
+
+	// a \r said in cherry can't start an irc command of its own
+	bridge.channel.SayAs("", "@alice", "hi\rQUIT :bye")
+	expect("PRIVMSG #retro :<@alice> hi QUIT :bye")
+
+	// a long cherry line is split in irc lines of 512 bytes at most
+	bridge.channel.SayAs("", "@alice", strings.Repeat("retro ", 100))
+
+	for i := 0; i < 2; i++ {
+		line, err := reader.ReadString('\n')
+
+		if err != nil || !strings.HasPrefix(line, "PRIVMSG #retro :<@alice> retro") || len(line) > 512 {
+			t.Errorf("got %q (%d bytes, %v), want a PRIVMSG of 512 bytes at most", line, len(line), err)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+)
+
+// JSONStore keeps the data of a store (mail, profiles...) in a json file,
+// rewritten on every change
+type JSONStore struct {
+	path         string // file storing the data. Empty keeps it in memory.
+	sync.RWMutex        // for reading/updating the data
+}
+
+// read the file into data. A missing file leaves data untouched.
+func (store *JSONStore) loadJSON(data interface{}) error {
+
+	if no(store.path) {
+		return nil
+	}
+
+	content, err := os.ReadFile(store.path)
+
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(content, data)
+}
+
+// write data to the file, through a temp file so a crash never leaves half
+// a file. Called with the lock held.
+func (store *JSONStore) saveJSON(data interface{}) error {
+
+	if no(store.path) {
+		return nil
+	}
+
+	content, err := json.MarshalIndent(data, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	tmp := store.path + ".tmp"
+
+	if err := os.WriteFile(tmp, content, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, store.path)
+}
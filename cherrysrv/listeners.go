@@ -0,0 +1,67 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+)
+
+// Every front-end (raw tcp, tls, telnet, websocket) hands a net.Conn speaking the
+// line protocol to newClient, so all users share the same clients and channels.
+
+// open a tcp4 listener on srvaddr
+func listenTCP(srvaddr string) net.Listener {
+
+	TCPAddr, err := net.ResolveTCPAddr("tcp", srvaddr)
+	if err != nil {
+		ERROR.Fatalf("Unable to resolve address on tcp4://%s (%s)", srvaddr, err)
+	}
+
+	server, err := net.ListenTCP("tcp4", TCPAddr)
+	if err != nil {
+		ERROR.Fatalf("Unable to serve on tcp4://%s (%s)", srvaddr, err)
+	}
+
+	return server
+}
+
+// open a tls listener on tlsaddr. Clients speak the same line protocol as in tcp.
+func listenTLS(tlsaddr string, certFile string, keyFile string) net.Listener {
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		ERROR.Fatalf("Unable to load tls certificate %s and key %s (%s)", certFile, keyFile, err)
+	}
+
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	return tls.NewListener(listenTCP(tlsaddr), config)
+}
+
+// accept connections until the listener is closed, wrapping them (if needed)
+// before creating the client
+func serve(server net.Listener, wrap func(net.Conn) net.Conn) {
+
+	trackListener(server)
+
+	for {
+		conn, err := server.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
+
+		if err != nil {
+			WARN.Printf("Unable to accept connection on %s (%s)", server.Addr(), err)
+			continue
+		}
+
+		if wrap != nil {
+			conn = wrap(conn)
+		}
+
+		go newClient(conn).clientLoop()
+	}
+}
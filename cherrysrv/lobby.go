@@ -0,0 +1,428 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LobbyConfig configures the game rooms, made from the game servers listed
+// in a FujiNet lobby
+type LobbyConfig struct {
+	URL  string        // lobby /viewFull endpoint. Empty disables the game rooms.
+	Poll time.Duration // time between reads of the lobby
+}
+
+// LobbyServer is a game server (a table) as listed by the lobby /viewFull
+type LobbyServer struct {
+	Game       string        `json:"game"`
+	Appkey     int           `json:"appkey"`
+	Server     string        `json:"server"`
+	Region     string        `json:"region"`
+	Serverurl  string        `json:"serverurl"`
+	Status     string        `json:"status"` // online, offline
+	Maxplayers int           `json:"maxplayers"`
+	Curplayers int           `json:"curplayers"`
+	Clients    []LobbyClient `json:"clients"`
+}
+
+type LobbyClient struct {
+	Platform string `json:"platform"`
+	Url      string `json:"url"`
+}
+
+// LobbyBot opens a game room (#5card-basement) for every game server in
+// the lobby and tells the room when players join or leave, as !game
+// events. Game servers can announce wins and such through the admin port
+// (POST /game).
+type LobbyBot struct {
+	url        string
+	poll       time.Duration // 0 only reads the lobby when asked (tests)
+	http       *http.Client
+	servers    map[string]LobbyServer // serverurl -> last read
+	channels   map[string]*Channel    // serverurl -> game room
+	read       bool                   // the lobby has been read at least once
+	sync.Mutex                        // for servers, channels and read
+}
+
+const LOBBY_TIMEOUT = 10 * time.Second
+
+var LOBBY *LobbyBot // nil if there's no lobby configured
+
+func newLobbyBot(config LobbyConfig) *LobbyBot {
+	return &LobbyBot{
+		url:      config.URL,
+		poll:     config.Poll,
+		http:     &http.Client{Timeout: LOBBY_TIMEOUT},
+		servers:  make(map[string]LobbyServer),
+		channels: make(map[string]*Channel),
+		Mutex:    sync.Mutex{},
+	}
+}
+
+func (lobby *LobbyBot) Name() string {
+	return "@lobby"
+}
+
+func (lobby *LobbyBot) Start(bot *BotClient) error {
+
+	if err := bot.AddCommand("lobby", "list the online game servers", lobby.do_lobby); err != nil {
+		return err
+	}
+
+	if lobby.poll > 0 {
+		go lobby.run(bot)
+	}
+
+	return nil
+}
+
+// the lobby bot doesn't talk
+func (lobby *LobbyBot) OnMessage(bot *BotClient, to string, from string, text string) {
+}
+
+// read the lobby every poll
+func (lobby *LobbyBot) run(bot *BotClient) {
+
+	for {
+		if err := lobby.Update(bot); err != nil {
+			WARN.Printf("Unable to read the lobby %s (%s)", lobby.url, err)
+		}
+
+		time.Sleep(lobby.poll)
+	}
+}
+
+// read the game servers from the lobby
+func (lobby *LobbyBot) fetch() ([]LobbyServer, error) {
+
+	response, err := lobby.http.Get(lobby.url)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusNotFound { // the lobby says "No servers available"
+		return nil, nil
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("lobby answered %s", response.Status)
+	}
+
+	var servers []LobbyServer
+
+	if err := json.NewDecoder(response.Body).Decode(&servers); err != nil {
+		return nil, err
+	}
+
+	return servers, nil
+}
+
+// a !game event for the room of a game server
+type gameEvent struct {
+	channel *Channel
+	text    string
+}
+
+// read the lobby, opening the rooms of new game servers and announcing the
+// changes in the ones already known. The rooms are joined and the events
+// sent once the lobby is unlocked, so a slow client can't hold /lobby.
+func (lobby *LobbyBot) Update(bot *BotClient) error {
+
+	servers, err := lobby.fetch()
+
+	if err != nil {
+		return err
+	}
+
+	var opened []*Channel
+	var events []gameEvent
+
+	event := func(channel *Channel, format string, args ...interface{}) {
+		events = append(events, gameEvent{channel, fmt.Sprintf(format, args...)})
+	}
+
+	lobby.Lock()
+
+	announce := lobby.read // nothing changed the first time, it's all new
+	lobby.read = true
+
+	seen := make(map[string]bool)
+
+	for _, server := range servers {
+		seen[server.Serverurl] = true
+
+		channel, ok := lobby.channels[server.Serverurl]
+
+		if !ok {
+			channel = openRoom(server)
+			lobby.channels[server.Serverurl] = channel
+			opened = append(opened, channel)
+		}
+
+		old, known := lobby.servers[server.Serverurl]
+		lobby.servers[server.Serverurl] = server
+
+		channel.setTopic(server.Topic())
+
+		if !announce {
+			continue
+		}
+
+		switch {
+		case !known || old.Status != server.Status:
+			event(channel, "%s is %s (%d/%d players)", server.Server, server.Status, server.Curplayers, server.Maxplayers)
+		case server.Curplayers > old.Curplayers:
+			event(channel, "%s joined %s (%d/%d players)", players(server.Curplayers-old.Curplayers), server.Server, server.Curplayers, server.Maxplayers)
+		case server.Curplayers < old.Curplayers:
+			event(channel, "%s left %s (%d/%d players)", players(old.Curplayers-server.Curplayers), server.Server, server.Curplayers, server.Maxplayers)
+		}
+	}
+
+	// servers not listed anymore have gone offline, their rooms stay
+	for serverurl, server := range lobby.servers {
+		if seen[serverurl] {
+			continue
+		}
+
+		if server.Status == "online" {
+			event(lobby.channels[serverurl], "%s is offline", server.Server)
+		}
+
+		delete(lobby.servers, serverurl)
+	}
+
+	lobby.Unlock()
+
+	for _, channel := range opened {
+		if err := bot.Join(channel.Name); err != nil {
+			WARN.Printf("%s unable to join %s (%s)", bot, channel, err)
+		}
+	}
+
+	for _, e := range events {
+		e.channel.Event("game", "%s", e.text)
+	}
+
+	return nil
+}
+
+func players(n int) string {
+
+	if n == 1 {
+		return "a player"
+	}
+
+	return fmt.Sprintf("%d players", n)
+}
+
+// the room of a game server, created if needed. Rooms are permanent, and
+// their names can start with a digit (#5card-basement), as only the lobby
+// creates them.
+func openRoom(server LobbyServer) *Channel {
+
+	channelName := gameChannelName(server)
+
+	channel, ok := CHANNELS.Load(channelName)
+
+	if !ok {
+		channel = NewChannelMain(channelName)
+
+		if stored, loaded := CHANNELS.LoadOrStore(channel.Key(), channel); loaded {
+			channel = stored
+		} else {
+			DEBUG.Printf("adding %s to CHANNELS for %s", channel, server.Serverurl)
+		}
+	}
+
+	return channel
+}
+
+// #game-table, as #5card-basement for the table basement of 5 Card Stud.
+// The table is the table= of the server url, or else the server name.
+// Words of the game are dropped from the end to fit in a channel name.
+func gameChannelName(server LobbyServer) string {
+
+	table := ""
+
+	if serverurl, err := url.Parse(server.Serverurl); err == nil {
+		table = slug(serverurl.Query().Get("table"))
+	}
+
+	if no(table) {
+		table = slug(server.Server)
+	}
+
+	var words []string
+
+	for _, word := range strings.Fields(server.Game) {
+		if word = slug(word); !no(word) {
+			words = append(words, word)
+		}
+	}
+
+	if len(words) == 0 {
+		words = []string{"game"}
+	}
+
+	name := func() string {
+		if no(table) {
+			return "#" + strings.Join(words, "")
+		}
+
+		return "#" + strings.Join(words, "") + "-" + table
+	}
+
+	for len(words) > 1 && len(name()) > 16 {
+		words = words[:len(words)-1]
+	}
+
+	return cutRunes(name(), 16)
+}
+
+// lowercase letters and digits of s
+func slug(s string) string {
+
+	var slug strings.Builder
+
+	for _, char := range strings.ToLower(s) {
+		if (char >= 'a' && char <= 'z') || (char >= '0' && char <= '9') {
+			slug.WriteRune(char)
+		}
+	}
+
+	return slug.String()
+}
+
+// topic of a game room: 5 Card Stud at The Basement (us), 2/8 players. Clients: atari apple2
+func (server LobbyServer) Topic() string {
+
+	platforms := make([]string, 0, len(server.Clients))
+
+	for _, client := range server.Clients {
+		platforms = append(platforms, client.Platform)
+	}
+
+	topic := fmt.Sprintf("%s at %s", server.Game, server.Server)
+
+	if !no(server.Region) {
+		topic += " (" + server.Region + ")"
+	}
+
+	if server.Status != "online" {
+		return topic + ", offline"
+	}
+
+	topic += fmt.Sprintf(", %d/%d players", server.Curplayers, server.Maxplayers)
+
+	if len(platforms) > 0 {
+		topic += ". Clients: " + strings.Join(platforms, " ")
+	}
+
+	return topic
+}
+
+// announce something that happened in a game server (as a win) in its room
+func (lobby *LobbyBot) Announce(serverurl string, text string) error {
+	lobby.Lock()
+	channel, ok := lobby.channels[serverurl]
+	lobby.Unlock()
+
+	if !ok {
+		return fmt.Errorf("%s is not in the lobby", serverurl)
+	}
+
+	channel.Event("game", "%s", oneLine(text))
+
+	return nil
+}
+
+// /lobby lists the online game servers, the busiest first
+func (lobby *LobbyBot) do_lobby(clt *Client, args string) {
+
+	lobby.Lock()
+
+	if !lobby.read {
+		lobby.Unlock()
+		clt.Say(">/lobby>0>the lobby is not available yet")
+
+		return
+	}
+
+	var servers []LobbyServer
+
+	for _, server := range lobby.servers {
+		if server.Status == "online" {
+			servers = append(servers, server)
+		}
+	}
+
+	channels := make(map[string]string)
+
+	for serverurl, channel := range lobby.channels {
+		channels[serverurl] = channel.Name
+	}
+
+	lobby.Unlock()
+
+	if len(servers) == 0 {
+		clt.Say(">/lobby>0>no game servers online")
+		return
+	}
+
+	sort.Slice(servers, func(i, j int) bool {
+		if servers[i].Curplayers != servers[j].Curplayers {
+			return servers[i].Curplayers > servers[j].Curplayers
+		}
+
+		if servers[i].Game != servers[j].Game {
+			return servers[i].Game < servers[j].Game
+		}
+
+		return servers[i].Server < servers[j].Server
+	})
+
+	lines := make([]string, 0, len(servers))
+
+	for _, server := range servers {
+		lines = append(lines, fmt.Sprintf("%s %d/%d %s at %s", channels[server.Serverurl], server.Curplayers, server.Maxplayers, server.Game, server.Server))
+	}
+
+	clt.SayN(">/lobby>", lines)
+}
+
+// POST /game on the admin port with serverurl (as in the lobby) and text
+func gameHandler(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST serverurl and text", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if LOBBY == nil {
+		http.Error(w, "there is no lobby configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	text := trim(r.FormValue("text"))
+
+	if no(text) {
+		http.Error(w, "text is empty", http.StatusBadRequest)
+		return
+	}
+
+	if err := LOBBY.Announce(r.FormValue("serverurl"), text); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
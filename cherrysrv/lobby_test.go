@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestGameChannelName(t *testing.T) {
+
+	tests := []struct {
+		game, server, serverurl string
+		want                    string
+	}{
+		{"5 Card Stud", "The Basement", "https://5card.carr-designs.com/?table=basement", "#5card-basement"},
+		{"5 Card Stud", "AI Room - 2 bots", "https://5card.carr-designs.com/?table=ai2", "#5cardstud-ai2"},
+		{"Texas Hold'em", "The Den", "https://holdem.example.com/?table=den", "#texasholdem-den"},
+		{"Texas Hold'em", "The Basement", "https://holdem.example.com/?table=basement", "#texas-basement"},
+		{"Super Chess", "chess.rogersm.net", "http://chess.rogersm.net/server", "#super-chessroge"},
+		{"!!", "", "http://example.com/", "#game"},
+	}
+
+	for _, test := range tests {
+		server := LobbyServer{Game: test.game, Server: test.server, Serverurl: test.serverurl}
+
+		if got := gameChannelName(server); got != test.want {
+			t.Errorf("gameChannelName(%q, %q) = %q, want %q", test.game, test.serverurl, got, test.want)
+		}
+	}
+}
+
+func TestLobby(t *testing.T) {
+
+	var (
+		viewFull = `[]`
+		lock     sync.Mutex
+	)
+
+	setLobby := func(json string) {
+		lock.Lock()
+		viewFull = json
+		lock.Unlock()
+	}
+
+	lobbyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		if viewFull == "" {
+			http.Error(w, `{"message":"No servers available","success":false}`, http.StatusNotFound)
+			return
+		}
+
+		w.Write([]byte(viewFull))
+	}))
+	defer lobbyServer.Close()
+
+	basement := `{"game":"5 Card Stud","appkey":1,"server":"The Basement","region":"us","serverurl":"https://5card.carr-designs.com/?table=basement","status":"online","maxplayers":8,"curplayers":%s,"clients":[{"platform":"atari","url":"tnfs://ec.tnfs.io/atari/5card.xex"}]}`
+	den := `{"game":"5 Card Stud","appkey":1,"server":"The Den","region":"us","serverurl":"https://5card.carr-designs.com/?table=den","status":"online","maxplayers":8,"curplayers":0,"clients":[]}`
+
+	setLobby("[" + strings.Replace(basement, "%s", "1", 1) + "," + den + "]")
+
+	LOBBY = newLobbyBot(LobbyConfig{URL: lobbyServer.URL + "/viewFull"})
+	bot, err := startBot(LOBBY)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		bot.Close()
+		LOBBY = nil
+		delete(COMMANDS, "lobby")
+		delete(BOT_COMMANDS, "lobby")
+		CHANNELS.Delete("#5card-basement")
+		CHANNELS.Delete("#5cardstud-den")
+	}()
+
+	server, out := net.Pipe()
+	defer out.Close()
+
+	clt := newClient(server)
+	defer clt.Close()
+
+	lines := bufio.NewReader(out)
+	out.SetReadDeadline(time.Now().Add(2 * time.Second))
+
+	expect := func(want string) {
+		t.Helper()
+
+		line, err := lines.ReadString('\n')
+
+		if err != nil || strings.TrimRight(line, "\n") != want {
+			t.Errorf("got %q (%v), want %q", line, err, want)
+		}
+	}
+
+	go exec(clt, "lobby", "")
+	expect(">/lobby>0>the lobby is not available yet")
+
+	if err := LOBBY.Update(bot); err != nil {
+		t.Fatal(err)
+	}
+
+	channel, ok := CHANNELS.Load("#5card-basement")
+
+	if !ok {
+		t.Fatalf("#5card-basement was not created")
+	}
+
+	if want := "5 Card Stud at The Basement (us), 1/8 players. Clients: atari"; channel.Topic() != want {
+		t.Errorf("topic %q, want %q", channel.Topic(), want)
+	}
+
+	// a player can join a room the lobby opened, even if its name starts with a digit
+	CLIENTS.Delete(clt.Key())
+	clt.Name = "@player"
+	clt.Status.Store(USER_LOGGED)
+	CLIENTS.Store(clt.Key(), clt)
+
+	go exec(clt, "join", "#5card-basement")
+	expect(">#5card-basement>@player>joined the channel")
+	expect(">#5card-basement>!topic>5 Card Stud at The Basement (us), 1/8 players. Clients: atari")
+
+	go exec(clt, "lobby", "")
+	expect(">/lobby>1>#5card-basement 1/8 5 Card Stud at The Basement")
+	expect(">/lobby>0>#5cardstud-den 0/8 5 Card Stud at The Den")
+
+	// joins and leaves are !game events in the room
+	setLobby("[" + strings.Replace(basement, "%s", "3", 1) + "," + den + "]")
+	go LOBBY.Update(bot)
+	expect(">#5card-basement>!game>2 players joined The Basement (3/8 players)")
+
+	setLobby("[" + strings.Replace(basement, "%s", "2", 1) + "]")
+	go LOBBY.Update(bot)
+	expect(">#5card-basement>!game>a player left The Basement (2/8 players)")
+
+	// game servers announce wins through the admin port
+	admin := httptest.NewServer(adminHandler())
+	defer admin.Close()
+
+	go func() {
+		res, err := admin.Client().PostForm(admin.URL+"/game", url.Values{
+			"serverurl": {"https://5card.carr-designs.com/?table=basement"},
+			"text":      {"Bob won 120 chips with a full house"},
+		})
+
+		if err != nil || res.StatusCode != http.StatusNoContent {
+			t.Errorf("POST /game failed: %v %v", res, err)
+		}
+	}()
+	expect(">#5card-basement>!game>Bob won 120 chips with a full house")
+
+	res, err := admin.Client().PostForm(admin.URL+"/game", url.Values{"serverurl": {"http://unknown/"}, "text": {"won"}})
+
+	if err != nil || res.StatusCode != http.StatusNotFound {
+		t.Errorf("POST /game of an unknown server: %v %v", res, err)
+	}
+
+	// no servers at all
+	setLobby("")
+	go LOBBY.Update(bot)
+	expect(">#5card-basement>!game>The Basement is offline")
+
+	go exec(clt, "lobby", "")
+	expect(">/lobby>0>no game servers online")
+}
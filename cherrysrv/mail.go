@@ -0,0 +1,261 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const MAILBOX_SIZE = 50 // mails kept per user
+
+// Mail is a message left to a registered user
+type Mail struct {
+	From string    `json:"from"`
+	Time time.Time `json:"time"`
+	Text string    `json:"text"`
+	Read bool      `json:"read,omitempty"`
+}
+
+// MailStore keeps the mailboxes of the registered users
+type MailStore struct {
+	JSONStore
+	boxes map[string][]Mail // @username -> mails, oldest first
+}
+
+func newMailStore(path string) *MailStore {
+	return &MailStore{
+		JSONStore: JSONStore{path: path},
+		boxes:     make(map[string][]Mail),
+	}
+}
+
+// load the mailboxes from disk. A missing file is an empty store.
+func (store *MailStore) Load() error {
+
+	boxes := make(map[string][]Mail)
+
+	if err := store.loadJSON(&boxes); err != nil {
+		return err
+	}
+
+	store.Lock()
+	store.boxes = boxes
+	store.Unlock()
+
+	return nil
+}
+
+// leave a mail in the mailbox of username
+func (store *MailStore) Send(username string, mail Mail) error {
+	store.Lock()
+	defer store.Unlock()
+
+	box := store.boxes[username]
+
+	if len(box) >= MAILBOX_SIZE {
+		return fmt.Errorf("the mailbox of %s is full", username)
+	}
+
+	store.setBox(username, append(box, mail))
+
+	if err := store.saveJSON(store.boxes); err != nil {
+		store.setBox(username, box) // not sent, so sending it again doesn't duplicate it
+		return err
+	}
+
+	return nil
+}
+
+// replace the mailbox of username, dropping it when empty
+func (store *MailStore) setBox(username string, box []Mail) {
+
+	if len(box) == 0 {
+		delete(store.boxes, username)
+		return
+	}
+
+	store.boxes[username] = box
+}
+
+// the mails of username, oldest first
+func (store *MailStore) List(username string) []Mail {
+	store.RLock()
+	defer store.RUnlock()
+
+	return append([]Mail(nil), store.boxes[username]...)
+}
+
+// number of mails not read yet
+func (store *MailStore) Unread(username string) (unread int) {
+	store.RLock()
+	defer store.RUnlock()
+
+	for _, mail := range store.boxes[username] {
+		if !mail.Read {
+			unread++
+		}
+	}
+
+	return unread
+}
+
+// mails are numbered as listed by SayN: 0 is the newest one
+func (store *MailStore) index(username string, n int) (int, error) {
+
+	box := store.boxes[username]
+
+	if n < 0 || n >= len(box) {
+		return 0, fmt.Errorf("there is no mail %d", n)
+	}
+
+	return len(box) - 1 - n, nil
+}
+
+// return mail n, marking it as read
+func (store *MailStore) Read(username string, n int) (Mail, error) {
+	store.Lock()
+	defer store.Unlock()
+
+	i, err := store.index(username, n)
+
+	if err != nil {
+		return Mail{}, err
+	}
+
+	mail := store.boxes[username][i]
+
+	if !mail.Read {
+		store.boxes[username][i].Read = true
+
+		if err := store.saveJSON(store.boxes); err != nil {
+			WARN.Printf("unable to save mail in %s (%s)", store.path, err)
+		}
+	}
+
+	return mail, nil
+}
+
+// delete mail n
+func (store *MailStore) Delete(username string, n int) error {
+	store.Lock()
+	defer store.Unlock()
+
+	i, err := store.index(username, n)
+
+	if err != nil {
+		return err
+	}
+
+	box := store.boxes[username]
+	store.setBox(username, append(box[:i:i], box[i+1:]...))
+
+	if err := store.saveJSON(store.boxes); err != nil {
+		store.setBox(username, box) // not deleted
+		return err
+	}
+
+	return nil
+}
+
+// tell a user logging in how many mails are waiting
+func announceMail(clt *Client) {
+
+	if unread := mailStore().Unread(clt.Name); unread > 0 {
+		clt.Say(">#main>!mail>you have %d unread mail(s), /mail to list them", unread)
+	}
+}
+
+// /mail, /mail @nick text, /mail read n, /mail del n
+func do_mail(clt *Client, args string) {
+
+	if !clt.isLogged() || !accountStore().Exists(clt.Name) {
+		clt.Say(">/mail>0>/mail requires you to be logged with a registered @nick")
+
+		return
+	}
+
+	first, rest := split2(args, " ")
+	rest = trim(rest)
+
+	switch {
+
+	case no(first):
+		mails := mailStore().List(clt.Name)
+
+		if len(mails) == 0 {
+			clt.Say(">/mail>0>no mail")
+			return
+		}
+
+		lines := make([]string, 0, len(mails))
+
+		for _, mail := range mails {
+			status := " "
+
+			if !mail.Read {
+				status = "*"
+			}
+
+			lines = append(lines, fmt.Sprintf("%s %s %s %s", status, mail.From, mail.Time.Format("2006-01-02 15:04"), mail.Text))
+		}
+
+		clt.SayN(">/mail>", lines)
+
+	case first == "read" || first == "del":
+		n, err := strconv.Atoi(rest)
+
+		if err != nil {
+			clt.Say(">/mail>0>/mail %s <n>", first)
+			return
+		}
+
+		if first == "del" {
+			if err := mailStore().Delete(clt.Name, n); err != nil {
+				clt.Say(">/mail>0>%s", err)
+				return
+			}
+
+			clt.Say(">/mail>0>mail %d deleted", n)
+			return
+		}
+
+		mail, err := mailStore().Read(clt.Name, n)
+
+		if err != nil {
+			clt.Say(">/mail>0>%s", err)
+			return
+		}
+
+		clt.Say("%s", replyLine("mail read", 0, fmt.Sprintf("%s %s %s", mail.From, mail.Time.Format("2006-01-02 15:04"), mail.Text)))
+
+	case first[0] == '@':
+		if no(rest) {
+			clt.Say(">/mail>0>/mail <@nick> <text>")
+			return
+		}
+
+		// online users get it right away, as /msg
+		if target, ok := findLoggedClient(first); ok {
+			target.Say("%s", messageLine(clt.Name, target.Name, rest))
+			METRICS.Message()
+			clt.Say(">/mail>0>%s is online, message sent", target)
+			return
+		}
+
+		if !accountStore().Exists(first) {
+			clt.Say(">/mail>0>%s is not a registered @nick", first)
+			return
+		}
+
+		if err := mailStore().Send(first, Mail{From: clt.Name, Time: time.Now().UTC(), Text: rest}); err != nil {
+			clt.Say(">/mail>0>unable to send mail: %s", err)
+			return
+		}
+
+		METRICS.Message()
+		clt.Say(">/mail>0>mail sent to %s", first)
+
+	default:
+		clt.Say(">/mail>0>/mail [@nick text|read n|del n]")
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMailStore(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "mail.json")
+	store := newMailStore(path)
+
+	for _, text := range []string{"first", "second", "third"} {
+		if err := store.Send("@roger", Mail{From: "@bob", Time: time.Now().UTC(), Text: text}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if unread := store.Unread("@roger"); unread != 3 {
+		t.Errorf("unread = %d, want 3", unread)
+	}
+
+	// 0 is the newest, as numbered by SayN
+	if mail, err := store.Read("@roger", 0); err != nil || mail.Text != "third" {
+		t.Errorf("Read(0) = %+v, %v, want third", mail, err)
+	}
+
+	if err := store.Delete("@roger", 2); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.Read("@roger", 2); err == nil {
+		t.Errorf("Read(2) of 2 mails should fail")
+	}
+
+	// a new store reads the same mailboxes
+	reloaded := newMailStore(path)
+
+	if err := reloaded.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	mails := reloaded.List("@roger")
+
+	if len(mails) != 2 || mails[0].Text != "second" || mails[0].Read || mails[1].Text != "third" || !mails[1].Read {
+		t.Errorf("reloaded mails = %+v, want second (unread) and third (read)", mails)
+	}
+
+	if unread := reloaded.Unread("@roger"); unread != 1 {
+		t.Errorf("unread after reload = %d, want 1", unread)
+	}
+
+	for i := len(mails); i < MAILBOX_SIZE; i++ {
+		store.Send("@roger", Mail{From: "@bob", Text: "spam"})
+	}
+
+	if err := store.Send("@roger", Mail{From: "@bob", Text: "one too many"}); err == nil {
+		t.Errorf("Send to a full mailbox should fail")
+	}
+
+	// what can't be saved is undone, so a retry doesn't duplicate it
+	store.path = filepath.Join(t.TempDir(), "missing", "mail.json")
+
+	if err := store.Send("@jim", Mail{From: "@bob", Text: "lost"}); err == nil {
+		t.Errorf("Send should fail when the mailboxes can't be saved")
+	}
+
+	if mails := store.List("@jim"); len(mails) != 0 {
+		t.Errorf("mails not saved = %+v, want none", mails)
+	}
+
+	if err := store.Delete("@roger", 0); err == nil {
+		t.Errorf("Delete should fail when the mailboxes can't be saved")
+	}
+
+	if mails := store.List("@roger"); len(mails) != MAILBOX_SIZE {
+		t.Errorf("%d mails after a failed Delete, want %d", len(mails), MAILBOX_SIZE)
+	}
+}
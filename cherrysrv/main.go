@@ -27,6 +27,7 @@ import (
 	"os/signal"
 	"runtime"
 	"strings"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -35,7 +36,6 @@ import (
 )
 
 /* https://github.com/avelino/awesome-go */
-/* Confing ini files https://github.com/sasbury/mini */
 /*
 https://github.com/skx/evalfilter
 https://github.com/madflojo/tasks
@@ -54,11 +54,16 @@ type do_command func(*Client, string)
 // This is our world!
 var (
 	COMMANDS  = make(map[string]do_command)
+	ROLES     = make(map[string]int)    // role required by a command, ROLE_USER if missing
 	CLIENTS   cmap.Map[string, *Client] // CLIENTS  cmap.Cmap
 	CHANNELS  cmap.Map[string, *Channel]
 	SCHEDULER *tasks.Scheduler
 	TIME      uint64
 	STARTEDON time.Time
+	ACCOUNTS  atomic.Pointer[AccountStore] // these are replaced by /reload, use accountStore() & co
+	ADMINS    atomic.Pointer[AdminList]
+	MAIL      atomic.Pointer[MailStore]
+	PROFILES  atomic.Pointer[ProfileStore]
 )
 
 const (
@@ -68,40 +73,38 @@ const (
 
 func main() {
 
-	var srvaddr string
 	var help bool
 
-	flag.StringVar(&srvaddr, "srvaddr", "", "<address:port> for tcp4 server")
+	init_flags()
 	flag.BoolVar(&help, "help", false, "show this help")
 
 	flag.Parse()
+	parse_flags()
 
-	if help || len(srvaddr) == 0 {
+	if help {
 		flag.PrintDefaults()
 		return
 	}
 
 	init_logger()
-	init_os_signal()
-	init_commands()
-	init_scheduler()
-	init_time()
 
-	TCPAddr, err := net.ResolveTCPAddr("tcp", srvaddr)
+	cfg, err := loadConfig()
+
 	if err != nil {
-		ERROR.Fatalf("Unable to resolve address on tcp4://%s (%s)", srvaddr, err)
-		return
+		ERROR.Fatalf("Unable to read the configuration (%s)", err)
 	}
 
-	server, err := net.ListenTCP("tcp4", TCPAddr)
-	if err != nil {
-		ERROR.Fatalf("Unable to serve on tcp4://%s (%s)", srvaddr, err)
+	if len(cfg.TCP)+len(cfg.Telnet)+len(cfg.WS)+len(cfg.TLS) == 0 {
+		fmt.Println("at least one listener (-srvaddr, -telnetaddr, -wsaddr, -tlsaddr) is required")
+		flag.PrintDefaults()
 		return
 	}
-	defer server.Close()
 
-	INFO.Printf("Started %s", STRINGVER)
-	INFO.Printf("Ready to serve on tcp://%s (tcp)", srvaddr)
+	if !no(cfg.TLS) && (no(cfg.TLSCert) || no(cfg.TLSKey)) {
+		fmt.Println("-tlsaddr requires -tlscert and -tlskey")
+		flag.PrintDefaults()
+		return
+	}
 
 	// We create tha main channel
 
@@ -109,14 +112,69 @@ func main() {
 	CHANNELS.Store(main_channel.Key(), main_channel)
 	DEBUG.Printf("adding %s to CHANNELS", main_channel)
 
-	for {
-		conn, err := server.AcceptTCP()
-		if err != nil {
-			WARN.Printf("Unable to accept connection on %s (%s)", srvaddr, err)
-			continue
+	if err := applyConfig(cfg); err != nil {
+		ERROR.Fatalf("%s", err)
+	}
+
+	if !no(cfg.Snapshot) {
+		if err := loadSnapshot(cfg.Snapshot); err != nil {
+			ERROR.Fatalf("Unable to restore the snapshot %s (%s)", cfg.Snapshot, err)
 		}
-		go newClient(conn).clientLoop()
 	}
+
+	init_os_signal()
+	init_commands()
+	init_bots()
+	init_scheduler()
+	init_time()
+
+	var tcp, tls, telnet net.Listener
+
+	if !no(cfg.TCP) {
+		tcp = listenTCP(cfg.TCP)
+	}
+
+	if !no(cfg.TLS) {
+		tls = listenTLS(cfg.TLS, cfg.TLSCert, cfg.TLSKey)
+	}
+
+	if !no(cfg.Telnet) {
+		telnet = listenTCP(cfg.Telnet)
+	}
+
+	INFO.Printf("Started %s", STRINGVER)
+
+	if tcp != nil {
+		INFO.Printf("Ready to serve on tcp://%s (tcp)", tcp.Addr())
+		go serve(tcp, nil)
+	}
+
+	if tls != nil {
+		INFO.Printf("Ready to serve on tls://%s (tls)", tls.Addr())
+		go serve(tls, nil)
+	}
+
+	if telnet != nil {
+		INFO.Printf("Ready to serve on telnet://%s (telnet)", telnet.Addr())
+		go serve(telnet, newTelnetConn)
+	}
+
+	if !no(cfg.WS) {
+		INFO.Printf("Ready to serve on ws://%s (websocket)", cfg.WS)
+		go listenWS(cfg.WS)
+	}
+
+	if !no(cfg.HTTP) {
+		INFO.Printf("Ready to serve http://%s/metrics (admin)", cfg.HTTP)
+		go listenAdminHTTP(cfg.HTTP)
+	}
+
+	if !no(cfg.IRC.Server) {
+		INFO.Printf("Bridging %s with irc://%s/%s", cfg.IRC.Bridge, cfg.IRC.Server, cfg.IRC.Channel)
+		go newIRCBridge(cfg.IRC).Run()
+	}
+
+	select {} // listeners run until the server drains, it ends through a signal or /shutdown
 }
 
 /*
@@ -181,7 +239,7 @@ func update_log_level(logger string, onoff string) error {
 }
 
 func init_scheduler() error {
-	SCHEDULER := tasks.New()
+	SCHEDULER = tasks.New()
 
 	TIME = 0
 
@@ -190,6 +248,8 @@ func init_scheduler() error {
 		TaskFunc: ticker("a 1 sec ticker"),
 	})
 
+	schedulePing()
+
 	return nil
 
 }
@@ -206,7 +266,8 @@ func ticker(s string) func() error {
 
 	return func() error {
 
-		TIME += 1
+		atomic.AddUint64(&TIME, 1)
+		METRICS.tick()
 
 		return nil
 	}
@@ -229,12 +290,13 @@ func SignalHandler(sigchan chan os.Signal) {
 
 		case syscall.SIGTERM:
 			WARN.Println("Got SIGTERM. Program will terminate cleanly now.")
-			Broadcast(">#main>!shutdown>Shutting down the server, it will re-start in a few minutes")
-			os.Exit(143)
+			go drain(config().ShutdownGrace, 143)
 		case syscall.SIGINT:
 			WARN.Println("Got SIGINT. Program will terminate cleanly now.")
-			Broadcast(">#main>!shutdown>Shutting down the server, it will re-start in a few minutes")
-			os.Exit(137)
+			go drain(config().ShutdownGrace, 137)
+		case syscall.SIGHUP:
+			INFO.Println("Got SIGHUP. Reloading the configuration.")
+			reloadConfig()
 		default:
 			INFO.Printf("Received signal %s. No action taken.", signal)
 		}
@@ -0,0 +1,15 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+// TestMain sets up the loggers and commands once, before any client goroutine
+// of the tests can use them
+func TestMain(m *testing.M) {
+	init_logger()
+	init_commands()
+
+	os.Exit(m.Run())
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// Metrics counts what happens in the server for the http admin port
+type Metrics struct {
+	messages   atomic.Uint64     // lines said in channels and private messages
+	rate       atomic.Uint64     // messages during the last second
+	last       uint64            // messages when the last second ended, only used by tick
+	commands   map[string]uint64 // times each command was executed
+	sync.Mutex                   // for commands
+}
+
+var METRICS = &Metrics{commands: make(map[string]uint64), Mutex: sync.Mutex{}}
+
+// a message has been said
+func (metrics *Metrics) Message() {
+	metrics.messages.Add(1)
+}
+
+// a command has been executed
+func (metrics *Metrics) Command(command string) {
+	metrics.Lock()
+	defer metrics.Unlock()
+
+	metrics.commands[command]++
+}
+
+// called every second by the scheduler to update the messages per second
+func (metrics *Metrics) tick() {
+
+	messages := metrics.messages.Load()
+
+	metrics.rate.Store(messages - metrics.last)
+	metrics.last = messages
+}
+
+// write the metrics in the prometheus text format
+func (metrics *Metrics) Write(output io.Writer) {
+
+	metric := func(name string, kind string, help string, value uint64) {
+		fmt.Fprintf(output, "# HELP %s %s\n# TYPE %s %s\n%s %d\n", name, help, name, kind, name, value)
+	}
+
+	var clients, logged uint64
+
+	CLIENTS.Range(func(key string, clt *Client) bool {
+		clients++
+
+		if clt.isLogged() {
+			logged++
+		}
+
+		return true
+	})
+
+	metric("cherry_clients", "gauge", "Connected clients.", clients)
+	metric("cherry_logged_users", "gauge", "Logged users.", logged)
+	metric("cherry_channels", "gauge", "Channels, including hidden ones.", uint64(CHANNELS.Count()))
+	metric("cherry_messages_total", "counter", "Messages said in channels and sent with /msg.", metrics.messages.Load())
+	metric("cherry_messages_per_second", "gauge", "Messages during the last second.", metrics.rate.Load())
+	metric("cherry_uptime_seconds", "counter", "Seconds since the server started.", atomic.LoadUint64(&TIME))
+
+	metrics.Lock()
+	defer metrics.Unlock()
+
+	commands := make([]string, 0, len(metrics.commands))
+
+	for command := range metrics.commands {
+		commands = append(commands, command)
+	}
+
+	sort.Strings(commands)
+
+	fmt.Fprintf(output, "# HELP cherry_commands_total Commands executed, by name.\n# TYPE cherry_commands_total counter\n")
+
+	for _, command := range commands {
+		fmt.Fprintf(output, "cherry_commands_total{command=%q} %d\n", command, metrics.commands[command])
+	}
+}
+
+// http handlers of the admin port
+func adminHandler() http.Handler {
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		METRICS.Write(w)
+	})
+
+	mux.HandleFunc("/audit", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Write(AUDIT.Recent())
+	})
+
+	mux.HandleFunc("/game", gameHandler)
+
+	return mux
+}
+
+// serve the http admin port, with no authentication: bind it to a private address
+func listenAdminHTTP(address string) {
+
+	if err := http.ListenAndServe(address, adminHandler()); err != nil {
+		ERROR.Fatalf("Unable to listen on %s for the http admin port (%s)", address, err)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAdminHTTP(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	if err := AUDIT.Open(path); err != nil {
+		t.Fatal(err)
+	}
+	defer AUDIT.Open("")
+
+	audit("join", "@tester", "#retro", "", "", "")
+	audit("kick", "@tester", "#retro", "@op", "", "")
+
+	METRICS.Command("metricstest")
+	METRICS.Command("metricstest")
+
+	server := httptest.NewServer(adminHandler())
+	defer server.Close()
+
+	get := func(path string) string {
+		t.Helper()
+
+		res, err := server.Client().Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+
+		body, _ := io.ReadAll(res.Body)
+
+		return string(body)
+	}
+
+	metrics := get("/metrics")
+
+	for _, want := range []string{
+		"# TYPE cherry_clients gauge\ncherry_clients ",
+		"# TYPE cherry_messages_total counter\n",
+		"cherry_messages_per_second ",
+		`cherry_commands_total{command="metricstest"} 2`,
+	} {
+		if !strings.Contains(metrics, want) {
+			t.Errorf("/metrics does not contain %q:\n%s", want, metrics)
+		}
+	}
+
+	// the same last entries in memory and in the file
+	file, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recent := strings.Split(strings.TrimSpace(get("/audit")), "\n")
+	lines := strings.Split(strings.TrimSpace(string(file)), "\n")
+
+	if len(lines) != 2 || recent[len(recent)-1] != lines[1] {
+		t.Fatalf("audit file %q does not match /audit %q", lines, recent)
+	}
+
+	var entry AuditEntry
+
+	if err := json.Unmarshal([]byte(lines[1]), &entry); err != nil {
+		t.Fatal(err)
+	}
+
+	if entry.Event != "kick" || entry.User != "@tester" || entry.Channel != "#retro" || entry.By != "@op" || entry.Time.IsZero() {
+		t.Errorf("unexpected audit entry %+v", entry)
+	}
+}
@@ -0,0 +1,239 @@
+package main
+
+// Channel operators. The user creating a channel becomes its operator and
+// can hand the role to other users in the channel with /op.
+
+// send the topic of the channel (if any) to the client
+func sayTopic(clt *Client, channel *Channel) {
+
+	if topic := channel.Topic(); !no(topic) {
+		clt.Say(">%s>!topic>%s", channel, topic)
+	}
+}
+
+// find the channel for an operator command checking clt is one of its operators
+func opChannel(clt *Client, command string, usage string, args string) (channel *Channel, rest string, ok bool) {
+
+	if !clt.isLogged() {
+		clt.Say(">/%s>0>/%s requires you to be logged", command, command)
+
+		return nil, "", false
+	}
+
+	channelName, rest := split2(args, " ")
+	rest = trim(rest)
+
+	if no(channelName) || no(rest) {
+		clt.Say(">/%s>0>/%s %s", command, command, usage)
+
+		return nil, "", false
+	}
+
+	channel, ok = CHANNELS.Load(channelName)
+
+	if !ok {
+		clt.Say(">/%s>0>%s is not a valid channel", command, channelName)
+
+		return nil, "", false
+	}
+
+	if !channel.isOp(clt) && !clt.isAdmin() {
+		clt.Say(">/%s>0>you're not an operator of %s", command, channel)
+
+		return nil, "", false
+	}
+
+	return channel, rest, true
+}
+
+// find a user for an operator command checking they are in the channel
+func opTarget(clt *Client, command string, channel *Channel, username string) (*Client, bool) {
+
+	target, ok := findLoggedClient(username)
+
+	if !ok || !channel.contains(target) {
+		clt.Say(">/%s>0>%s is not in %s", command, username, channel)
+
+		return nil, false
+	}
+
+	return target, true
+}
+
+// show or change the topic of a channel
+func do_topic(clt *Client, args string) {
+
+	channelName, topic := split2(args, " ")
+
+	if no(topic) {
+
+		if !clt.isLogged() {
+			clt.Say(">/topic>0>/topic requires you to be logged")
+			return
+		}
+
+		if no(channelName) {
+			clt.Say(">/topic>0>/topic <#channel> [topic]")
+			return
+		}
+
+		channel, ok := CHANNELS.Load(channelName)
+
+		if !ok {
+			clt.Say(">/topic>0>%s is not a valid channel", channelName)
+			return
+		}
+
+		if no(channel.Topic()) {
+			clt.Say(">/topic>0>%s has no topic", channel)
+			return
+		}
+
+		clt.Say(">/topic>0>%s %s", channel, channel.Topic())
+
+		return
+	}
+
+	channel, topic, ok := opChannel(clt, "topic", "<#channel> [topic]", args)
+
+	if !ok {
+		return
+	}
+
+	channel.setTopic(topic)
+	channel.Event("topic", "%s", topic)
+
+	INFO.Printf("%s changed the topic of %s to '%s'", clt, channel, topic)
+}
+
+// remove a user from a channel
+func do_kick(clt *Client, args string) {
+
+	channel, username, ok := opChannel(clt, "kick", "<#channel> <@nick>", args)
+
+	if !ok {
+		return
+	}
+
+	target, ok := opTarget(clt, "kick", channel, username)
+
+	if !ok {
+		return
+	}
+
+	channel.Event("kick", "%s was kicked by %s", target, clt)
+	channel.removeClient(target)
+	audit("kick", target.Name, channel.Name, clt.Name, "", "")
+
+	INFO.Printf("%s kicked %s from %s", clt, target, channel)
+}
+
+// remove a user from a channel and don't let them join again
+func do_ban(clt *Client, args string) {
+
+	channel, username, ok := opChannel(clt, "ban", "<#channel> <@nick>", args)
+
+	if !ok {
+		return
+	}
+
+	if _, err := ValidUsername(username); err != nil {
+		clt.Say(">/ban>0>%s is not a valid username because %s", username, err.Error())
+		return
+	}
+
+	channel.setBan(username, true)
+
+	if target, ok := findLoggedClient(username); ok && channel.contains(target) {
+		channel.Event("ban", "%s was banned by %s", target, clt)
+		channel.removeClient(target)
+	}
+
+	clt.Say(">/ban>0>%s is banned from %s", username, channel)
+	audit("ban", username, channel.Name, clt.Name, "", "")
+
+	INFO.Printf("%s banned %s from %s", clt, username, channel)
+}
+
+// let a banned user join a channel again
+func do_unban(clt *Client, args string) {
+
+	channel, username, ok := opChannel(clt, "unban", "<#channel> <@nick>", args)
+
+	if !ok {
+		return
+	}
+
+	channel.setBan(username, false)
+
+	clt.Say(">/unban>0>%s can join %s again", username, channel)
+}
+
+// make another user of the channel an operator
+func do_op(clt *Client, args string) {
+
+	channel, username, ok := opChannel(clt, "op", "<#channel> <@nick>", args)
+
+	if !ok {
+		return
+	}
+
+	target, ok := opTarget(clt, "op", channel, username)
+
+	if !ok {
+		return
+	}
+
+	channel.setOp(target)
+	channel.Event("op", "%s is now operator (by %s)", target, clt)
+}
+
+// let a user join an invite only channel
+func do_invite(clt *Client, args string) {
+
+	channel, username, ok := opChannel(clt, "invite", "<#channel> <@nick>", args)
+
+	if !ok {
+		return
+	}
+
+	if _, err := ValidUsername(username); err != nil {
+		clt.Say(">/invite>0>%s is not a valid username because %s", username, err.Error())
+		return
+	}
+
+	channel.invite(username)
+
+	if target, ok := findLoggedClient(username); ok {
+		target.Say(">%s>!invite>%s invited you to join %s", channel, clt, channel)
+	}
+
+	clt.Say(">/invite>0>%s can now join %s", username, channel)
+}
+
+// change the mode of a channel: +i (invite only) or -i (open)
+func do_mode(clt *Client, args string) {
+
+	channel, mode, ok := opChannel(clt, "mode", "<#channel> <+i|-i>", args)
+
+	if !ok {
+		return
+	}
+
+	switch mode {
+	case "+i":
+		channel.setInviteOnly(true)
+
+		// current users do not need an invitation to come back
+		for _, name := range channel.ClientNames() {
+			channel.invite(name)
+		}
+
+		channel.Event("mode", "%s is now invite only (by %s)", channel, clt)
+	case "-i":
+		channel.setInviteOnly(false)
+		channel.Event("mode", "%s is now open (by %s)", channel, clt)
+	default:
+		clt.Say(">/mode>0>/mode <#channel> <+i|-i>")
+	}
+}
@@ -31,6 +31,13 @@ func exec(clt *Client, command string, args string) (string, error) {
 	_, ok := COMMANDS[command]
 
 	if ok {
+		if role, ok := ROLES[command]; ok && clt.Role.Load() < int32(role) {
+			clt.Say("%s", replyLine(command, 0, "/"+command+" requires admin privileges"))
+
+			return command, nil
+		}
+
+		METRICS.Command(command)
 		COMMANDS[command](clt, args)
 
 		return command, nil
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// keys of a profile that /setinfo can set, with their max length
+var PROFILE_KEYS = map[string]int{
+	"bio":      200,
+	"location": 32,
+	"platform": 32,
+	"web":      64,
+}
+
+// Profile is what a registered user tells about themselves with /setinfo
+type Profile map[string]string
+
+// ProfileStore keeps the profiles of the registered users
+type ProfileStore struct {
+	JSONStore
+	profiles map[string]Profile // @username -> profile
+}
+
+func newProfileStore(path string) *ProfileStore {
+	return &ProfileStore{
+		JSONStore: JSONStore{path: path},
+		profiles:  make(map[string]Profile),
+	}
+}
+
+// load the profiles from disk. A missing file is an empty store.
+func (store *ProfileStore) Load() error {
+
+	profiles := make(map[string]Profile)
+
+	if err := store.loadJSON(&profiles); err != nil {
+		return err
+	}
+
+	store.Lock()
+	store.profiles = profiles
+	store.Unlock()
+
+	return nil
+}
+
+// the profile of username, empty if it has none
+func (store *ProfileStore) Get(username string) Profile {
+	store.RLock()
+	defer store.RUnlock()
+
+	profile := make(Profile, len(store.profiles[username]))
+
+	for key, value := range store.profiles[username] {
+		profile[key] = value
+	}
+
+	return profile
+}
+
+// set a key of the profile of username. An empty value deletes it.
+func (store *ProfileStore) Set(username string, key string, value string) error {
+
+	max, ok := PROFILE_KEYS[key]
+
+	if !ok {
+		return fmt.Errorf("%s is not a profile key (%s)", key, strings.Join(sortedProfileKeys(), ", "))
+	}
+
+	if len(value) > max {
+		return fmt.Errorf("%s cannot be longer than %d chars", key, max)
+	}
+
+	if key == "platform" && !isASCIIPrintable(value) { // as /caps platform=
+		return fmt.Errorf("%s can only contain ASCII letters and numbers", key)
+	}
+
+	if !isPrintable(value) {
+		return fmt.Errorf("%s cannot contain control codes", key)
+	}
+
+	store.Lock()
+	defer store.Unlock()
+
+	previous := store.profiles[username]
+	profile := make(Profile, len(previous)+1)
+
+	for k, v := range previous {
+		profile[k] = v
+	}
+
+	if no(value) {
+		delete(profile, key)
+	} else {
+		profile[key] = value
+	}
+
+	store.setProfile(username, profile)
+
+	if err := store.saveJSON(store.profiles); err != nil {
+		store.setProfile(username, previous) // not set
+		return err
+	}
+
+	return nil
+}
+
+// replace the profile of username, dropping it when empty
+func (store *ProfileStore) setProfile(username string, profile Profile) {
+
+	if len(profile) == 0 {
+		delete(store.profiles, username)
+		return
+	}
+
+	store.profiles[username] = profile
+}
+
+func sortedProfileKeys() []string {
+
+	keys := make([]string, 0, len(PROFILE_KEYS))
+
+	for key := range PROFILE_KEYS {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+// "1h2m" instead of "1h2m3.456s"
+func roundDuration(d time.Duration) string {
+
+	if d < time.Minute {
+		return d.Round(time.Second).String()
+	}
+
+	return strings.TrimSuffix(d.Round(time.Minute).String(), "0s")
+}
+
+// /whois <@nick>: session of an online user and profile of a registered one
+func do_whois(clt *Client, args string) {
+
+	if !clt.isLogged() {
+		clt.Say(">/whois>0>/whois requires you to be logged")
+		return
+	}
+
+	name, _ := split2(trim(args), " ")
+
+	if no(name) {
+		clt.Say(">/whois>0>/whois <@nick>")
+		return
+	}
+
+	target, online := findLoggedClient(name)
+	registered := accountStore().Exists(name)
+
+	if !online && !registered {
+		clt.Say(">/whois>0>%s is not online nor registered", name)
+		return
+	}
+
+	kind := "guest"
+
+	switch {
+	case online && target.isBot():
+		kind = "bot"
+	case registered:
+		kind = "registered"
+	}
+
+	lines := []string{fmt.Sprintf("%s (%s)", name, kind)}
+	profile := profileStore().Get(name)
+
+	if online {
+		connected := target.connected.UTC()
+
+		lines = append(lines,
+			fmt.Sprintf("connected %s (%s ago)", connected.Format("2006-01-02 15:04 MST"), roundDuration(time.Since(connected))),
+			fmt.Sprintf("idle %s", roundDuration(target.idleTime())))
+
+		var channels []string
+
+		CHANNELS.Range(func(key string, channel *Channel) bool {
+			if !channel.isHidden() && channel.contains(target) {
+				channels = append(channels, channel.Name)
+			}
+
+			return true
+		})
+
+		sort.Strings(channels)
+
+		if len(channels) > 0 {
+			lines = append(lines, "channels "+strings.Join(channels, " "))
+		}
+
+		if away := target.Away(); !no(away) {
+			lines = append(lines, "away "+away)
+		}
+
+		if platform := target.Caps().Platform; !no(platform) {
+			profile["platform"] = platform // what the client says beats what the user said
+		}
+	} else {
+		lines = append(lines, "offline")
+	}
+
+	for _, key := range sortedProfileKeys() {
+		if value, ok := profile[key]; ok {
+			lines = append(lines, key+" "+value)
+		}
+	}
+
+	clt.SayN(">/whois "+name+">", lines)
+}
+
+// /setinfo shows your profile, /setinfo <key> [value] sets (or deletes) a key
+func do_setinfo(clt *Client, args string) {
+
+	if !clt.isLogged() || !accountStore().Exists(clt.Name) {
+		clt.Say(">/setinfo>0>/setinfo requires you to be logged with a registered @nick")
+		return
+	}
+
+	key, value := split2(trim(args), " ")
+	key = strings.ToLower(key)
+	value = trim(value)
+
+	if no(key) {
+		profile := profileStore().Get(clt.Name)
+
+		if len(profile) == 0 {
+			clt.Say(">/setinfo>0>your profile is empty, /setinfo <%s> <value>", strings.Join(sortedProfileKeys(), "|"))
+			return
+		}
+
+		var lines []string
+
+		for _, key := range sortedProfileKeys() {
+			if value, ok := profile[key]; ok {
+				lines = append(lines, key+" "+value)
+			}
+		}
+
+		clt.SayN(">/setinfo>", lines)
+
+		return
+	}
+
+	if err := profileStore().Set(clt.Name, key, value); err != nil {
+		clt.Say(">/setinfo>0>%s", err)
+		return
+	}
+
+	if no(value) {
+		clt.Say(">/setinfo>0>%s deleted", key)
+		return
+	}
+
+	clt.Say("%s", replyLine("setinfo", 0, key+" "+value))
+}
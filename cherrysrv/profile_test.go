@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bufio"
+	"net"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProfileStore(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "profiles.json")
+	store := newProfileStore(path)
+
+	if err := store.Set("@roger", "bio", "writes cherry server"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Set("@roger", "platform", "atari"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Set("@roger", "shoesize", "44"); err == nil {
+		t.Errorf("Set of an unknown key should fail")
+	}
+
+	if err := store.Set("@roger", "location", strings.Repeat("x", 33)); err == nil {
+		t.Errorf("Set of a value too long should fail")
+	}
+
+	if err := store.Set("@roger", "platform", "atari 800"); err == nil {
+		t.Errorf("Set of a platform that /caps would refuse should fail")
+	}
+
+	if err := store.Set("@roger", "bio", "hi\x1b[2J"); err == nil {
+		t.Errorf("Set of a value with control codes should fail")
+	}
+
+	if err := store.Set("@roger", "platform", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// a new store reads the same profiles
+	reloaded := newProfileStore(path)
+
+	if err := reloaded.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	if profile := reloaded.Get("@roger"); len(profile) != 1 || profile["bio"] != "writes cherry server" {
+		t.Errorf("reloaded profile = %v, want only the bio", profile)
+	}
+
+	if profile := reloaded.Get("@nobody"); len(profile) != 0 {
+		t.Errorf("profile of @nobody = %v, want it empty", profile)
+	}
+
+	// what can't be saved is undone
+	store.path = filepath.Join(t.TempDir(), "missing", "profiles.json")
+
+	if err := store.Set("@roger", "bio", "changed"); err == nil {
+		t.Errorf("Set should fail when the profiles can't be saved")
+	}
+
+	if profile := store.Get("@roger"); profile["bio"] != "writes cherry server" {
+		t.Errorf("bio after a failed Set = %q, want the saved one", profile["bio"])
+	}
+}
+
+func TestWhois(t *testing.T) {
+
+	accounts := newAccountStore(filepath.Join(t.TempDir(), "accounts.db"))
+
+	if err := accounts.Register("@known", "secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	oldAccounts, oldProfiles := accountStore(), profileStore()
+	ACCOUNTS.Store(accounts)
+	PROFILES.Store(newProfileStore(""))
+	defer func() {
+		ACCOUNTS.Store(oldAccounts)
+		PROFILES.Store(oldProfiles)
+	}()
+
+	server, out := net.Pipe()
+	defer out.Close()
+
+	clt := newClient(server)
+	CLIENTS.Delete(clt.Key())
+	clt.Name = "@known"
+	clt.Status.Store(USER_LOGGED)
+	CLIENTS.Store(clt.Key(), clt)
+	defer clt.Close()
+
+	channel := newChannel("#whois", false)
+	channel.addClient(clt)
+	CHANNELS.Store(channel.Key(), channel)
+	defer CHANNELS.Delete(channel.Key())
+
+	hidden := newChannel("#secret", true)
+	hidden.addClient(clt)
+	CHANNELS.Store(hidden.Key(), hidden)
+	defer CHANNELS.Delete(hidden.Key())
+
+	lines := bufio.NewReader(out)
+	out.SetReadDeadline(time.Now().Add(2 * time.Second))
+
+	expect := func(want string) { // regexp
+		t.Helper()
+
+		line, err := lines.ReadString('\n')
+
+		if err != nil || !regexp.MustCompile("^"+want+"$").MatchString(strings.TrimRight(line, "\n")) {
+			t.Errorf("got %q (%v), want %s", line, err, want)
+		}
+	}
+
+	go exec(clt, "setinfo", "bio I like 8 bits")
+	expect(">/setinfo>0>bio I like 8 bits")
+
+	go exec(clt, "setinfo", "platform c64")
+	expect(">/setinfo>0>platform c64")
+
+	go exec(clt, "caps", "platform=atari")
+	expect(">/caps>0>client eol=lf maxline=255 events=full platform=atari charset=utf8")
+
+	go exec(clt, "away", "lunch")
+	expect(">/away>0>you're away: lunch")
+
+	go exec(clt, "whois", "@known")
+	expect(`>/whois @known>6>@known \(registered\)`)
+	expect(`>/whois @known>5>connected \d{4}-\d\d-\d\d \d\d:\d\d UTC \(\d+s ago\)`)
+	expect(`>/whois @known>4>idle \d+s`)
+	expect(`>/whois @known>3>channels #whois`) // not #secret
+	expect(`>/whois @known>2>away lunch`)
+	expect(`>/whois @known>1>bio I like 8 bits`)
+	expect(`>/whois @known>0>platform atari`) // the client beats the profile
+
+	go exec(clt, "whois", "@nobody")
+	expect(`>/whois>0>@nobody is not online nor registered`)
+
+	// an offline registered user shows the profile
+	CLIENTS.Delete(clt.Key())
+	clt.Name = "@other"
+	CLIENTS.Store(clt.Key(), clt)
+
+	go exec(clt, "whois", "@known")
+	expect(`>/whois @known>3>@known \(registered\)`)
+	expect(`>/whois @known>2>offline`)
+	expect(`>/whois @known>1>bio I like 8 bits`)
+	expect(`>/whois @known>0>platform c64`)
+
+	go exec(clt, "setinfo", "bio guest")
+	expect(`>/setinfo>0>/setinfo requires you to be logged with a registered @nick`)
+}
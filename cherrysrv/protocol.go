@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// The protocol is line based. Clients send commands or text ended by \n
+// (\r\n is accepted too) and the server sends lines made of fields
+// separated by '>':
+//
+//	>#channel>@sender>text    something said in a channel
+//	>/command>n>text          reply to a command, n counts down to 0
+//	>#channel>!event>text     something that happened in a channel
+//
+// Lines longer than the maxline of the client (MAX_LINE by default) are cut.
+
+// returned with the cut line when a line sent by the client was too long
+var ErrLineTooLong = errors.New("line too long")
+
+// LineReader reads the lines sent by a client. It keeps its buffer between
+// reads, so lines sent together in one packet are never lost.
+type LineReader struct {
+	reader *bufio.Reader
+}
+
+func newLineReader(r io.Reader) *LineReader {
+	return &LineReader{reader: bufio.NewReader(r)}
+}
+
+// ReadLine returns the next line without its end of line, \n or eol (0x9b
+// for ATASCII clients). A line of max chars or more (counting the \n, as
+// shorten does) is cut and the rest of it is discarded, returning
+// ErrLineTooLong with what was kept. On any other error the line is what
+// was read before it.
+func (lr *LineReader) ReadLine(max int, eol byte) (string, error) {
+
+	var line []byte
+
+	for {
+		b, err := lr.reader.ReadByte()
+
+		if err != nil {
+			return strings.TrimSuffix(string(line), "\r"), err
+		}
+
+		if b == '\n' || b == eol {
+			break
+		}
+
+		if len(line) < max { // no need to keep more than what's cut
+			line = append(line, b)
+		}
+	}
+
+	text := strings.TrimSuffix(string(line), "\r")
+
+	if len(text) >= max {
+		return cutRunes(text, max-1), ErrLineTooLong
+	}
+
+	return text, nil
+}
+
+// keep text on a single line, so it can't fake lines of the protocol
+func oneLine(text string) string {
+	return strings.NewReplacer("\r\n", " ", "\n", " ", "\r", " ").Replace(text)
+}
+
+// >#channel>@sender>text
+func messageLine(channel string, sender string, text string) string {
+	return ">" + channel + ">" + sender + ">" + oneLine(text)
+}
+
+// >/command>n>text
+func replyLine(command string, n int, text string) string {
+	return ">/" + command + ">" + strconv.Itoa(n) + ">" + oneLine(text)
+}
+
+// >#channel>!event>text
+func eventLine(channel string, event string, text string) string {
+	return ">" + channel + ">!" + event + ">" + oneLine(text)
+}
+
+// split a line sent by the server in its three fields, without the \n:
+// ">#channel>@sender>text" -> "#channel", "@sender", "text"
+func splitLine(line string) (first string, second string, text string, ok bool) {
+
+	line = strings.TrimSuffix(line, "\n")
+
+	if !strings.HasPrefix(line, ">") {
+		return "", "", "", false
+	}
+
+	first, rest := split2(line[1:], ">")
+
+	if !strings.Contains(rest, ">") {
+		return "", "", "", false
+	}
+
+	second, text = split2(rest, ">")
+
+	return first, second, text, true
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadLine(t *testing.T) {
+
+	long := strings.Repeat("x", 300)
+	huge := strings.Repeat("y", 10000) // longer than the bufio buffer
+
+	type read struct {
+		line string
+		err  error
+	}
+
+	tests := []struct {
+		name  string
+		input string
+		max   int
+		eol   byte
+		want  []read
+	}{
+		{"one line", "/who\n", MAX_LINE, '\n', []read{{"/who", nil}, {"", io.EOF}}},
+		{"pipelined lines", "/who\n/nusers\nhello\n", MAX_LINE, '\n', []read{{"/who", nil}, {"/nusers", nil}, {"hello", nil}, {"", io.EOF}}},
+		{"crlf", "/who\r\n/users\r\n", MAX_LINE, '\n', []read{{"/who", nil}, {"/users", nil}}},
+		{"empty line", "\n/who\n", MAX_LINE, '\n', []read{{"", nil}, {"/who", nil}}},
+		{"unfinished line", "/who\n/nus", MAX_LINE, '\n', []read{{"/who", nil}, {"/nus", io.EOF}}},
+		{"longest line", strings.Repeat("z", MAX_LINE-1) + "\n", MAX_LINE, '\n', []read{{strings.Repeat("z", MAX_LINE-1), nil}}},
+		{"too long", long + "\n/who\n", MAX_LINE, '\n', []read{{long[:MAX_LINE-1], ErrLineTooLong}, {"/who", nil}}},
+		{"longer than the buffer", huge + "\n/who\n", MAX_LINE, '\n', []read{{huge[:MAX_LINE-1], ErrLineTooLong}, {"/who", nil}}},
+		{"bigger maxline", long + "\n", MAX_LINE_CAP, '\n', []read{{long, nil}}},
+		{"utf8 not split", strings.Repeat("x", 8) + "ééé\n", 10, '\n', []read{{strings.Repeat("x", 8), ErrLineTooLong}}},
+		{"atascii eol", "/who\x9b/nusers\n", MAX_LINE, 0x9b, []read{{"/who", nil}, {"/nusers", nil}}},
+		{"0x9b in utf8", "ś\n", MAX_LINE, '\n', []read{{"ś", nil}}}, // ś is 0xc5 0x9b
+		{"carriage return inside", "a\rb\r\n", MAX_LINE, '\n', []read{{"a\rb", nil}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reader := newLineReader(strings.NewReader(test.input))
+
+			for _, want := range test.want {
+				line, err := reader.ReadLine(test.max, test.eol)
+
+				if line != want.line || err != want.err {
+					t.Errorf("ReadLine() = %q, %v, want %q, %v", cutRunes(line, 40), err, cutRunes(want.line, 40), want.err)
+				}
+			}
+		})
+	}
+}
+
+func TestLineEncoders(t *testing.T) {
+
+	tests := []struct {
+		got  string
+		want string
+	}{
+		{messageLine("#main", "@alice", "hello"), ">#main>@alice>hello"},
+		{messageLine("#main", "@alice", "a > b"), ">#main>@alice>a > b"},
+		{messageLine("#main", "@alice", "two\nlines"), ">#main>@alice>two lines"},
+		{replyLine("who", 0, "@alice"), ">/who>0>@alice"},
+		{replyLine("users #main", 12, "@bob"), ">/users #main>12>@bob"},
+		{replyLine("who", 0, "fake\r\n>#main>@admin>hi"), ">/who>0>fake >#main>@admin>hi"},
+		{eventLine("#main", "login", "@alice has logged in"), ">#main>!login>@alice has logged in"},
+		{eventLine("#main", "topic", ""), ">#main>!topic>"},
+	}
+
+	for _, test := range tests {
+		if test.got != test.want {
+			t.Errorf("got %q, want %q", test.got, test.want)
+		}
+	}
+}
+
+func TestSplitLine(t *testing.T) {
+
+	tests := []struct {
+		line                string
+		first, second, text string
+		ok                  bool
+	}{
+		{">#main>@alice>hello\n", "#main", "@alice", "hello", true},
+		{">#main>@alice>a > b", "#main", "@alice", "a > b", true},
+		{">/users>3>@bob", "/users", "3", "@bob", true},
+		{">#main>!login>@alice has logged in", "#main", "!login", "@alice has logged in", true},
+		{">@alice>@bob>psst", "@alice", "@bob", "psst", true},
+		{">#main>@alice>", "#main", "@alice", "", true},
+		{">#main>@alice", "", "", "", false},
+		{"#main>@alice>hello", "", "", "", false},
+		{"", "", "", "", false},
+	}
+
+	for _, test := range tests {
+		first, second, text, ok := splitLine(test.line)
+
+		if first != test.first || second != test.second || text != test.text || ok != test.ok {
+			t.Errorf("splitLine(%q) = %q, %q, %q, %v, want %q, %q, %q, %v", test.line, first, second, text, ok, test.first, test.second, test.text, test.ok)
+		}
+	}
+}
+
+// lines sent together in one write are all answered
+func TestPipelinedLines(t *testing.T) {
+
+	server, out := net.Pipe()
+	defer out.Close()
+
+	in := bufio.NewReader(out)
+	out.SetDeadline(time.Now().Add(2 * time.Second))
+
+	clt := newClient(server)
+	defer CLIENTS.Delete(clt.Key())
+
+	go clt.clientLoop()
+
+	if _, err := in.ReadString('\n'); err != nil { // welcome
+		t.Fatal(err)
+	}
+
+	go out.Write([]byte("/who\n/nosuchcommand\n" + strings.Repeat("x", 300) + "\n/who\n"))
+
+	for _, want := range []string{
+		">/who>0>" + clt.Name,
+		">/nosuchcommand>0>command nosuchcommand does not exist",
+		">/say>0>" + strings.Repeat("x", 10), // cut, not lost
+		">/who>0>" + clt.Name,
+	} {
+		line, err := in.ReadString('\n')
+
+		if err != nil || !strings.HasPrefix(line, want) {
+			t.Errorf("got %q (%v), want %q", line, err, want)
+		}
+	}
+}
+
+// text kept by the server (mail, profiles, history files) can't fake lines
+func TestSayNOneLine(t *testing.T) {
+
+	server, out := net.Pipe()
+	defer out.Close()
+
+	clt := newClient(server)
+	CLIENTS.Delete(clt.Key())
+
+	go clt.SayN(">/mail>", []string{"* @bob hi\r>#main>!admin>@bob is root", "* @ann bye"})
+
+	in := bufio.NewReader(out)
+	out.SetReadDeadline(time.Now().Add(2 * time.Second))
+
+	for _, want := range []string{">/mail>1>* @bob hi >#main>!admin>@bob is root\n", ">/mail>0>* @ann bye\n"} {
+		if line, err := in.ReadString('\n'); line != want {
+			t.Errorf("got %q (%v), want %q", line, err, want)
+		}
+	}
+}
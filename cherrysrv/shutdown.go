@@ -0,0 +1,149 @@
+package main
+
+import (
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// a shutdown in progress, nil if there's none
+var (
+	shutdownCancel chan struct{}
+	shutdownLock   sync.Mutex
+)
+
+// listeners to close when draining, so no new client connects
+var (
+	openListeners []io.Closer
+	listenersLock sync.Mutex
+	draining      atomic.Bool
+)
+
+// stop the server in minutes, telling every user each minute.
+// Returns false if a shutdown is already in progress.
+func scheduleShutdown(minutes int) bool {
+	shutdownLock.Lock()
+	defer shutdownLock.Unlock()
+
+	if shutdownCancel != nil {
+		return false
+	}
+
+	cancel := make(chan struct{})
+	shutdownCancel = cancel
+
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+
+		for left := minutes; left > 0; left-- {
+			Broadcast(">#main>!shutdown>the server will shut down in %d minute(s)", left)
+
+			select {
+			case <-ticker.C:
+			case <-cancel:
+				return
+			}
+		}
+
+		WARN.Println("Scheduled shutdown. Program will terminate cleanly now.")
+		drain(0, 0)
+	}()
+
+	return true
+}
+
+// stop a shutdown in progress. Returns false if there was none.
+func cancelShutdown() bool {
+	shutdownLock.Lock()
+	defer shutdownLock.Unlock()
+
+	if shutdownCancel == nil {
+		return false
+	}
+
+	close(shutdownCancel)
+	shutdownCancel = nil
+
+	return true
+}
+
+// remember a listener to close it when draining
+func trackListener(listener io.Closer) {
+	listenersLock.Lock()
+	defer listenersLock.Unlock()
+
+	openListeners = append(openListeners, listener)
+}
+
+// when to tell the users how long is left of a grace period:
+// every minute, then 30, 10, 5, 3, 2 and 1 seconds before the end
+func countdownSteps(grace time.Duration) (steps []time.Duration) {
+
+	for left := grace.Truncate(time.Minute); left >= time.Minute; left -= time.Minute {
+		steps = append(steps, left)
+	}
+
+	for _, left := range []time.Duration{30, 10, 5, 3, 2, 1} {
+		if left *= time.Second; left < grace && (len(steps) == 0 || left < steps[len(steps)-1]) {
+			steps = append(steps, left)
+		}
+	}
+
+	if len(steps) == 0 || steps[0] != grace {
+		steps = append([]time.Duration{grace}, steps...)
+	}
+
+	return steps
+}
+
+// stop the server cleanly: no new connections, a countdown of grace for
+// the connected users, a snapshot of the channels and then exit with code.
+// A second call while draining exits right away.
+func drain(grace time.Duration, code int) {
+
+	if !draining.CompareAndSwap(false, true) {
+		WARN.Printf("Already draining, terminating now")
+		os.Exit(code)
+	}
+
+	listenersLock.Lock()
+	for _, listener := range openListeners {
+		listener.Close()
+	}
+	listenersLock.Unlock()
+
+	if grace > 0 {
+		WARN.Printf("Draining the server for %s", grace)
+
+		end := time.Now().Add(grace)
+
+		for _, left := range countdownSteps(grace) {
+			time.Sleep(time.Until(end.Add(-left)))
+			Broadcast(">#main>!shutdown>the server will shut down in %s", left)
+		}
+
+		time.Sleep(time.Until(end))
+	}
+
+	Broadcast(">#main>!shutdown>%s", config().ShutdownMessage)
+
+	if path := config().Snapshot; !no(path) {
+		if err := saveSnapshot(path); err != nil {
+			ERROR.Printf("Unable to save the snapshot %s (%s)", path, err)
+		} else {
+			INFO.Printf("Channels saved in %s", path)
+		}
+	}
+
+	CLIENTS.Range(func(key string, clt *Client) bool {
+		clt.Status.Store(USER_LOGGINOUT)
+		clt.conn.Close()
+
+		return true
+	})
+
+	os.Exit(code)
+}
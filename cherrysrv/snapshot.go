@@ -0,0 +1,212 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/lrita/cmap"
+)
+
+// ChannelSnapshot is the state of a channel kept across restarts
+type ChannelSnapshot struct {
+	Name       string   `json:"name"`
+	Hidden     bool     `json:"hidden,omitempty"`
+	Topic      string   `json:"topic,omitempty"`
+	Ops        []string `json:"ops,omitempty"`
+	Bans       []string `json:"bans,omitempty"`
+	Invited    []string `json:"invited,omitempty"`
+	InviteOnly bool     `json:"invite_only,omitempty"`
+	Members    []string `json:"members,omitempty"` // rejoined when they login again
+}
+
+// Snapshot are the channels of the server when it stopped
+type Snapshot struct {
+	Time     time.Time         `json:"time"`
+	Channels []ChannelSnapshot `json:"channels"`
+}
+
+// channels to rejoin by @name, restored from the snapshot
+var REJOIN cmap.Map[string, []string]
+
+func sortedNames(names map[string]bool) []string {
+
+	output := make([]string, 0, len(names))
+
+	for name, ok := range names {
+		if ok {
+			output = append(output, name)
+		}
+	}
+
+	sort.Strings(output)
+
+	return output
+}
+
+func (channel *Channel) snapshot() ChannelSnapshot {
+	channel.RLock()
+	defer channel.RUnlock()
+
+	snapshot := ChannelSnapshot{
+		Name:       channel.Name,
+		Hidden:     channel.hidden,
+		Topic:      channel.topic,
+		Ops:        sortedNames(channel.ops),
+		Bans:       sortedNames(channel.bans),
+		Invited:    sortedNames(channel.invited),
+		InviteOnly: channel.inviteOnly,
+	}
+
+	if channel.Name != "#main" { // everybody is in #main
+		for _, clt := range channel.clients {
+			snapshot.Members = append(snapshot.Members, clt.Name)
+		}
+
+		sort.Strings(snapshot.Members)
+	}
+
+	return snapshot
+}
+
+// write the channels to path
+func saveSnapshot(path string) error {
+
+	snapshot := Snapshot{Time: time.Now().UTC()}
+
+	CHANNELS.Range(func(key string, channel *Channel) bool {
+		snapshot.Channels = append(snapshot.Channels, channel.snapshot())
+		return true
+	})
+
+	sort.Slice(snapshot.Channels, func(i, j int) bool {
+		return snapshot.Channels[i].Name < snapshot.Channels[j].Name
+	})
+
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+// restore the channels saved in path. A missing file is no snapshot.
+func loadSnapshot(path string) error {
+
+	data, err := os.ReadFile(path)
+
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	var snapshot Snapshot
+
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return err
+	}
+
+	for _, saved := range snapshot.Channels {
+
+		if _, err := ValidChannelname(saved.Name); err != nil && saved.Name != "#main" {
+			WARN.Printf("%s: ignoring channel %s (%s)", path, saved.Name, err)
+			continue
+		}
+
+		channel, ok := CHANNELS.Load(saved.Name)
+
+		if !ok {
+			channel = newChannel(saved.Name, saved.Hidden)
+			CHANNELS.Store(channel.Key(), channel)
+			DEBUG.Printf("adding %s to CHANNELS", channel)
+		}
+
+		channel.restore(saved)
+
+		for _, member := range registered(saved.Members) {
+			channels, _ := REJOIN.Load(member)
+			REJOIN.Store(member, append(channels, channel.Name))
+		}
+	}
+
+	INFO.Printf("%d channels restored from %s (%s)", len(snapshot.Channels), path, snapshot.Time.Format(time.RFC3339))
+
+	return nil
+}
+
+func (channel *Channel) restore(saved ChannelSnapshot) {
+	channel.Lock()
+	defer channel.Unlock()
+
+	channel.topic = saved.Topic
+	channel.inviteOnly = saved.InviteOnly
+
+	for _, name := range registered(saved.Ops) {
+		channel.ops[name] = true
+	}
+
+	for _, name := range saved.Bans {
+		channel.bans[name] = true
+	}
+
+	for _, name := range registered(saved.Invited) {
+		channel.invited[name] = true
+	}
+
+	if saved.InviteOnly { // members were in, let them back
+		for _, name := range registered(saved.Members) {
+			channel.invited[name] = true
+		}
+	}
+}
+
+// the registered @names. Anyone can take the @name of a guest after a
+// restart, so guests don't get back what they had.
+func registered(names []string) (output []string) {
+
+	for _, name := range names {
+		if accountStore().Exists(name) {
+			output = append(output, name)
+		}
+	}
+
+	return output
+}
+
+// join clt to the channels it was in when the server stopped
+func rejoinChannels(clt *Client) {
+
+	channels, ok := REJOIN.Load(clt.Name)
+
+	if !ok {
+		return
+	}
+
+	REJOIN.Delete(clt.Name)
+
+	for _, channelName := range channels {
+		channel, ok := CHANNELS.Load(channelName)
+
+		if !ok || channel.contains(clt) || channel.canJoin(clt) != nil {
+			continue
+		}
+
+		if channel.addClient(clt) {
+			audit("join", clt.Name, channel.Name, "", "", "")
+			channel.Say(clt, "rejoined the channel")
+			sayTopic(clt, channel)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bufio"
+	"net"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCountdownSteps(t *testing.T) {
+
+	s := time.Second
+
+	tests := []struct {
+		grace time.Duration
+		want  []time.Duration
+	}{
+		{3 * s, []time.Duration{3 * s, 2 * s, 1 * s}},
+		{10 * s, []time.Duration{10 * s, 5 * s, 3 * s, 2 * s, 1 * s}},
+		{90 * s, []time.Duration{90 * s, 60 * s, 30 * s, 10 * s, 5 * s, 3 * s, 2 * s, 1 * s}},
+		{2 * time.Minute, []time.Duration{120 * s, 60 * s, 30 * s, 10 * s, 5 * s, 3 * s, 2 * s, 1 * s}},
+	}
+
+	for _, test := range tests {
+		if got := countdownSteps(test.grace); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("countdownSteps(%s) = %v, want %v", test.grace, got, test.want)
+		}
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+
+	accounts := newAccountStore(filepath.Join(t.TempDir(), "accounts.db"))
+
+	if err := accounts.Register("@snapper", "secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	oldAccounts := accountStore()
+	ACCOUNTS.Store(accounts)
+	defer ACCOUNTS.Store(oldAccounts)
+
+	server, out := net.Pipe()
+	defer out.Close()
+
+	lines := make(chan string, 10)
+
+	go func() {
+		scanner := bufio.NewScanner(out)
+
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+
+	clt := newClient(server)
+	CLIENTS.Delete(clt.Key())
+	clt.Name = "@snapper"
+	clt.Status.Store(USER_LOGGED)
+	defer CLIENTS.Delete(clt.Key())
+
+	guestServer, guestOut := net.Pipe()
+	defer guestOut.Close()
+
+	guest := newClient(guestServer)
+	CLIENTS.Delete(guest.Key())
+	guest.Name = "@guest"
+	guest.Status.Store(USER_LOGGED)
+
+	channel := newChannel("#snap", false)
+	channel.addClient(clt)
+	channel.setOp(clt)
+	channel.addClient(guest)
+	channel.setOp(guest)
+	channel.invite("@visitor")
+	channel.setTopic("saved topic")
+	channel.setBan("@troll", true)
+	channel.setInviteOnly(true)
+
+	CHANNELS.Store(channel.Key(), channel)
+	defer CHANNELS.Delete(channel.Key())
+
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+
+	if err := saveSnapshot(path); err != nil {
+		t.Fatal(err)
+	}
+
+	// as a new server
+	CHANNELS.Delete(channel.Key())
+
+	if err := loadSnapshot(path); err != nil {
+		t.Fatal(err)
+	}
+
+	restored, ok := CHANNELS.Load("#snap")
+
+	if !ok {
+		t.Fatalf("#snap was not restored")
+	}
+
+	// the guests are not operators nor invited anymore
+	want := ChannelSnapshot{Name: "#snap", Topic: "saved topic", Ops: []string{"@snapper"}, Bans: []string{"@troll"}, Invited: []string{"@snapper"}, InviteOnly: true}
+
+	if got := restored.snapshot(); !reflect.DeepEqual(got, want) {
+		t.Errorf("restored %+v, want %+v", got, want)
+	}
+
+	rejoinChannels(clt)
+
+	if !restored.contains(clt) {
+		t.Fatalf("@snapper was not rejoined to the invite only #snap")
+	}
+
+	for _, want := range []string{">#snap>@snapper>rejoined the channel", ">#snap>!topic>saved topic"} {
+		select {
+		case line := <-lines:
+			if !strings.HasPrefix(line, want) {
+				t.Errorf("got %q, want %q", line, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timeout waiting for %q", want)
+		}
+	}
+
+	if _, ok := REJOIN.Load("@snapper"); ok {
+		t.Errorf("@snapper is still pending to rejoin")
+	}
+
+	if _, ok := REJOIN.Load("@guest"); ok {
+		t.Errorf("@guest is pending to rejoin")
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"net"
+)
+
+// telnet commands and options (RFC 854, RFC 1184)
+const (
+	TELNET_SE       = 240
+	TELNET_SB       = 250
+	TELNET_WILL     = 251
+	TELNET_WONT     = 252
+	TELNET_DO       = 253
+	TELNET_DONT     = 254
+	TELNET_IAC      = 255
+	TELNET_LINEMODE = 34
+	LINEMODE_MODE   = 1
+	LINEMODE_EDIT   = 1
+)
+
+// states of the telnet input parser
+const (
+	TELNET_DATA   = iota // plain text
+	TELNET_CR            // after a \r
+	TELNET_CMD           // after IAC
+	TELNET_OPTION        // after IAC WILL/WONT/DO/DONT
+	TELNET_SUB           // inside IAC SB ... IAC SE
+	TELNET_SUBIAC        // IAC inside a subnegotiation
+)
+
+// telnetConn strips the telnet negotiation from the input and adapts the
+// output to the NVT (\r\n line endings, IAC escaped). The client is asked
+// to edit lines locally (LINEMODE) so it only sends complete lines.
+type telnetConn struct {
+	net.Conn
+	state int  // TELNET_DATA, TELNET_CR...
+	verb  byte // WILL/WONT/DO/DONT being parsed
+	raw   []byte
+}
+
+func newTelnetConn(conn net.Conn) net.Conn {
+
+	telnet := &telnetConn{
+		Conn:  conn,
+		state: TELNET_DATA,
+		raw:   make([]byte, 512),
+	}
+
+	telnet.Conn.Write([]byte{TELNET_IAC, TELNET_DO, TELNET_LINEMODE})
+
+	return telnet
+}
+
+// read data sent by the telnet client, answering any negotiation found
+func (telnet *telnetConn) Read(p []byte) (int, error) {
+
+	if len(p) > len(telnet.raw) {
+		p = p[:len(telnet.raw)]
+	}
+
+	for {
+		n, err := telnet.Conn.Read(telnet.raw[:len(p)])
+
+		data := telnet.filter(telnet.raw[:n])
+		copy(p, data)
+
+		if len(data) > 0 || err != nil {
+			return len(data), err
+		}
+	}
+}
+
+// remove the telnet commands from raw, returning only the text sent
+func (telnet *telnetConn) filter(raw []byte) []byte {
+
+	data := raw[:0] // data is never longer than raw, so we filter in place
+
+	for _, b := range raw {
+
+		switch telnet.state {
+
+		case TELNET_DATA, TELNET_CR:
+			if b == TELNET_IAC {
+				telnet.state = TELNET_CMD
+				continue
+			}
+
+			// \r\0 is a bare carriage return, it ends the line as well
+			if telnet.state == TELNET_CR && b == 0 {
+				data = append(data, '\n')
+				telnet.state = TELNET_DATA
+				continue
+			}
+
+			telnet.state = TELNET_DATA
+
+			if b == '\r' {
+				telnet.state = TELNET_CR
+			}
+
+			data = append(data, b)
+
+		case TELNET_CMD:
+			switch b {
+			case TELNET_IAC:
+				data = append(data, b) // escaped 255
+				telnet.state = TELNET_DATA
+			case TELNET_WILL, TELNET_WONT, TELNET_DO, TELNET_DONT:
+				telnet.verb = b
+				telnet.state = TELNET_OPTION
+			case TELNET_SB:
+				telnet.state = TELNET_SUB
+			default:
+				telnet.state = TELNET_DATA // NOP, GA, AYT... are ignored
+			}
+
+		case TELNET_OPTION:
+			telnet.negotiate(telnet.verb, b)
+			telnet.state = TELNET_DATA
+
+		case TELNET_SUB:
+			if b == TELNET_IAC {
+				telnet.state = TELNET_SUBIAC
+			}
+
+		case TELNET_SUBIAC:
+			telnet.state = TELNET_SUB
+
+			if b == TELNET_SE {
+				telnet.state = TELNET_DATA
+			}
+		}
+	}
+
+	return data
+}
+
+// answer to a WILL/WONT/DO/DONT from the client. We only want LINEMODE,
+// everything else is refused.
+func (telnet *telnetConn) negotiate(verb byte, option byte) {
+
+	switch verb {
+	case TELNET_WILL:
+		if option == TELNET_LINEMODE {
+			telnet.Conn.Write([]byte{TELNET_IAC, TELNET_SB, TELNET_LINEMODE, LINEMODE_MODE, LINEMODE_EDIT, TELNET_IAC, TELNET_SE})
+			return
+		}
+
+		telnet.Conn.Write([]byte{TELNET_IAC, TELNET_DONT, option})
+	case TELNET_DO:
+		telnet.Conn.Write([]byte{TELNET_IAC, TELNET_WONT, option})
+	}
+}
+
+// write data to the telnet client as NVT
+func (telnet *telnetConn) Write(p []byte) (int, error) {
+
+	data := bytes.ReplaceAll(p, []byte{TELNET_IAC}, []byte{TELNET_IAC, TELNET_IAC})
+	data = bytes.ReplaceAll(data, []byte("\n"), []byte("\r\n"))
+
+	if _, err := telnet.Conn.Write(data); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
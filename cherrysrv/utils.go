@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"reflect"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/dchest/uniuri"
 )
@@ -32,20 +34,38 @@ func isASCIIPrintable(str string) bool {
 	return true
 }
 
+// check if str has no control codes
+func isPrintable(str string) bool {
+
+	for _, r := range str {
+		if !unicode.IsPrint(r) {
+			return false
+		}
+	}
+
+	return true
+}
+
 func ValidUsername(username string) (validusername string, err error) {
 
 	var notvalid string
 
+	if len(username) < 2 {
+		return notvalid, fmt.Errorf("username is too short")
+	}
+
 	if username[0] != '@' {
 		return notvalid, fmt.Errorf("username must start with '@'")
 	}
 
-	if username == "@srv" {
+	cfg := config()
+
+	if cfg.isReserved(username) {
 		return notvalid, fmt.Errorf("this is a reserved name that cannot be used")
 	}
 
-	if len(username) > 16 {
-		return notvalid, fmt.Errorf("username cannot be longer than 16 chars")
+	if len(username) > cfg.MaxNameLength {
+		return notvalid, fmt.Errorf("username cannot be longer than %d chars", cfg.MaxNameLength)
 	}
 
 	if isDigit(username[1]) {
@@ -63,6 +83,10 @@ func ValidChannelname(channelname string) (vaalidchannelname string, err error)
 
 	var notvalid string
 
+	if len(channelname) < 2 {
+		return notvalid, fmt.Errorf("channelname is too short")
+	}
+
 	if channelname[0] != '#' {
 		return notvalid, fmt.Errorf("channelname must start with '#'")
 	}
@@ -129,11 +153,16 @@ func trim(s string) string {
 	return strings.Trim(s, " \t\n\r")
 }
 
-// if len(line) >= 255, reduce it to 254 + "\n"
-func shorten255(line string) string {
-	if len(line) >= 255 {
-		return line[:254] + "\n"
+// cut a line to at most n bytes without breaking a utf8 sequence
+func cutRunes(line string, n int) string {
+
+	if len(line) <= n {
+		return line
+	}
+
+	for n > 0 && !utf8.RuneStart(line[n]) {
+		n--
 	}
 
-	return line
+	return line[:n]
 }
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// wsConn adapts a websocket to the line protocol: every message received is
+// a line and every line written is sent as a text message (without \n).
+type wsConn struct {
+	ws         *websocket.Conn
+	input      []byte // pending data of the last message received
+	output     []byte // pending data not ending in \n yet
+	err        error  // a failed websocket cannot be read again
+	sync.Mutex        // websockets do not support concurrent writers
+}
+
+func newWSConn(ws *websocket.Conn) net.Conn {
+	return &wsConn{
+		ws:    ws,
+		Mutex: sync.Mutex{},
+	}
+}
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	CheckOrigin:     func(r *http.Request) bool { return true }, // web clients can be hosted anywhere
+}
+
+// http handler upgrading the connection and starting a new client
+func serveWS(w http.ResponseWriter, r *http.Request) {
+
+	ws, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		WARN.Printf("Unable to upgrade websocket from %s (%s)", r.RemoteAddr, err)
+		return
+	}
+
+	go newClient(newWSConn(ws)).clientLoop()
+}
+
+// serve websockets on wsaddr until the server drains
+func listenWS(wsaddr string) {
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", serveWS)
+
+	server := &http.Server{Addr: wsaddr, Handler: mux}
+	trackListener(server)
+
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		ERROR.Fatalf("Unable to serve on ws://%s (%s)", wsaddr, err)
+	}
+}
+
+func (conn *wsConn) Read(p []byte) (int, error) {
+
+	for len(conn.input) == 0 {
+
+		if conn.err != nil {
+			return 0, conn.err
+		}
+
+		_, message, err := conn.ws.ReadMessage()
+		if err != nil {
+			conn.err = err
+			return 0, err
+		}
+
+		if !bytes.HasSuffix(message, []byte("\n")) {
+			message = append(message, '\n')
+		}
+
+		conn.input = message
+	}
+
+	n := copy(p, conn.input)
+	conn.input = conn.input[n:]
+
+	return n, nil
+}
+
+func (conn *wsConn) Write(p []byte) (int, error) {
+	conn.Lock()
+	defer conn.Unlock()
+
+	conn.output = append(conn.output, p...)
+
+	for {
+		eol := bytes.IndexByte(conn.output, '\n')
+
+		if eol < 0 {
+			return len(p), nil
+		}
+
+		if err := conn.ws.WriteMessage(websocket.TextMessage, conn.output[:eol]); err != nil {
+			conn.output = nil
+			return 0, err
+		}
+
+		conn.output = conn.output[eol+1:]
+	}
+}
+
+func (conn *wsConn) Close() error {
+	return conn.ws.Close()
+}
+
+func (conn *wsConn) LocalAddr() net.Addr {
+	return conn.ws.LocalAddr()
+}
+
+func (conn *wsConn) RemoteAddr() net.Addr {
+	return conn.ws.RemoteAddr()
+}
+
+func (conn *wsConn) SetDeadline(t time.Time) error {
+	return conn.ws.UnderlyingConn().SetDeadline(t)
+}
+
+func (conn *wsConn) SetReadDeadline(t time.Time) error {
+	return conn.ws.SetReadDeadline(t)
+}
+
+func (conn *wsConn) SetWriteDeadline(t time.Time) error {
+	return conn.ws.SetWriteDeadline(t)
+}
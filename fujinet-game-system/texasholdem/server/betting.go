@@ -0,0 +1,79 @@
+package main
+
+/*
+Betting structures
+
+Every table has a betting structure, which sets the legal bet and raise amounts.
+The move codes are the same for all of them, so 8-bit clients need no changes:
+the server computes the amounts and only offers the codes that are legal.
+
+	No-limit    BL 1xBB, BH 2xBB, RL min-raise, RH 2x min-raise, AI anytime
+	Pot-limit   BL 1xBB, BH pot sized bet, RL min-raise, RH pot sized raise,
+	            AI only up to the size of the pot
+	Fixed-limit BL/RL one fixed bet (the big blind pre-flop and on the flop,
+	            twice it on the turn and river), no BH/RH, at most a bet and 3
+	            raises per street, AI only up to the fixed amount
+*/
+
+type BettingStructure string
+
+const (
+	NO_LIMIT    BettingStructure = "nl"
+	POT_LIMIT   BettingStructure = "pl"
+	FIXED_LIMIT BettingStructure = "fl"
+)
+
+// name is the betting structure as hand histories write it
+func (b BettingStructure) name() string {
+	switch b {
+	case POT_LIMIT:
+		return "Pot Limit"
+	case FIXED_LIMIT:
+		return "Limit"
+	}
+	return "No Limit"
+}
+
+// Bets allowed per street in fixed-limit: a bet (the big blind pre-flop) and 3 raises
+const FIXED_LIMIT_CAP = 4
+
+// fixedBetSize is the size of a bet or raise in fixed-limit: the small bet on the
+// first two streets, the big bet (2x) on the turn and river
+func (state *GameState) fixedBetSize() int {
+	if state.Round >= 3 {
+		return 2 * state.blinds().BigBlind
+	}
+	return state.blinds().BigBlind
+}
+
+// fixedLimitCapped tells whether the bets of this street have reached the cap.
+// Pre-flop the big blind counts as the bet.
+func (state *GameState) fixedLimitCapped() bool {
+	bets := state.raiseCount
+	if state.Round == 1 {
+		bets++
+	}
+	return bets >= FIXED_LIMIT_CAP
+}
+
+// maxChipAmount returns the most chips the active player may put in right now,
+// or -1 if there is no limit
+func (state *GameState) maxChipAmount() int {
+	player := state.Players[state.ActivePlayer]
+	callAmount := state.currentBet - player.Bet
+	if callAmount < 0 {
+		callAmount = 0
+	}
+
+	switch state.betting {
+	case POT_LIMIT:
+		// Call, then raise by the size of the pot after the call
+		return callAmount + state.Pot + callAmount
+	case FIXED_LIMIT:
+		if state.fixedLimitCapped() {
+			return callAmount
+		}
+		return callAmount + state.fixedBetSize()
+	}
+	return -1
+}
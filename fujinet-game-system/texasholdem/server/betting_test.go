@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// moveNames returns the valid moves as "CODE Name" strings for easy comparison
+func moveNames(moves []validMove) []string {
+	names := []string{}
+	for _, m := range moves {
+		names = append(names, m.Move+" "+m.Name)
+	}
+	return names
+}
+
+// newBettingTable deals the first hand of a 3 bot table with the given betting
+// structure. The first to act pre-flop faces the big blind with a pot of 15.
+func newBettingTable(t *testing.T, betting BettingStructure) *GameState {
+	t.Helper()
+	useFastTimers(t)
+	state := newBotTable(3, 51)
+	state.betting = betting
+	state.RunGameLogic()
+	require.Equal(t, 1, state.Round)
+	require.Equal(t, SB+BB, state.Pot)
+	return state
+}
+
+// TestBettingStructureMoves verifies the legal amounts of each betting structure
+// behind the same 2-character codes
+func TestBettingStructureMoves(t *testing.T) {
+	tests := []struct {
+		betting BettingStructure
+		preflop []string // first to act, facing the big blind
+		short   []string // same, with only 30 chips
+		flop    []string // first to act on a 60 chip pot, no bet yet
+		turn    []string // first to act on the turn, no bet yet
+	}{
+		{NO_LIMIT,
+			[]string{"FO Fold", "CA Call 10", "RL Raise 20", "RH Raise 30", "AI All-in 1000"},
+			[]string{"FO Fold", "CA Call 10", "RL Raise 20", "RH Raise 30", "AI All-in 30"},
+			[]string{"FO Fold", "CH Check", "BL Bet 10", "BH Bet 20", "AI All-in 1000"},
+			[]string{"FO Fold", "CH Check", "BL Bet 10", "BH Bet 20", "AI All-in 1000"}},
+		{POT_LIMIT,
+			[]string{"FO Fold", "CA Call 10", "RL Raise 20", "RH Raise 35"},
+			[]string{"FO Fold", "CA Call 10", "RL Raise 20", "AI All-in 30"},
+			[]string{"FO Fold", "CH Check", "BL Bet 10", "BH Bet 60"},
+			[]string{"FO Fold", "CH Check", "BL Bet 10", "BH Bet 60"}},
+		{FIXED_LIMIT,
+			[]string{"FO Fold", "CA Call 10", "RL Raise 20"},
+			[]string{"FO Fold", "CA Call 10", "RL Raise 20"},
+			[]string{"FO Fold", "CH Check", "BL Bet 10"},
+			[]string{"FO Fold", "CH Check", "BL Bet 20"}},
+	}
+
+	for _, test := range tests {
+		t.Run(string(test.betting), func(t *testing.T) {
+			state := newBettingTable(t, test.betting)
+			assert.Equal(t, test.preflop, moveNames(state.getValidMoves()), "pre-flop")
+
+			player := &state.Players[state.ActivePlayer]
+			purse := player.Purse
+			player.Purse = 30
+			assert.Equal(t, test.short, moveNames(state.getValidMoves()), "short stack pre-flop")
+			player.Purse = purse
+
+			state.Round = 2
+			state.resetPlayersForNewBettingRound()
+			state.Players[state.ActivePlayer].Purse = 1000
+			state.Pot = 60
+			assert.Equal(t, test.flop, moveNames(state.getValidMoves()), "flop")
+
+			state.Round = 3
+			assert.Equal(t, test.turn, moveNames(state.getValidMoves()), "turn")
+		})
+	}
+}
+
+// TestFixedLimitRaiseCap verifies fixed-limit allows a bet and 3 raises per street
+// (pre-flop the big blind is the bet), and the all-in only up to the limit
+func TestFixedLimitRaiseCap(t *testing.T) {
+	state := newBettingTable(t, FIXED_LIMIT)
+
+	for _, want := range []string{"RL Raise 20", "RL Raise 25", "RL Raise 30"} {
+		moves := moveNames(state.getValidMoves())
+		require.Contains(t, moves, want)
+		require.True(t, state.performMove("RL"))
+	}
+	assert.Equal(t, 40, state.currentBet, "big blind plus 3 raises")
+
+	// Capped: the next player can only call or fold
+	assert.Equal(t, []string{"FO Fold", "CA Call 20"}, moveNames(state.getValidMoves()))
+
+	// With fewer chips than the call, the all-in is the call for less
+	state.Players[state.ActivePlayer].Purse = 15
+	assert.Equal(t, []string{"FO Fold", "AI All-in 15"}, moveNames(state.getValidMoves()))
+
+	// Moves that are not legal are refused
+	state.Players[state.ActivePlayer].Purse = 1000
+	assert.False(t, state.performMove("RL"))
+	assert.False(t, state.performMove("AI"))
+	assert.False(t, state.performMove("RH"))
+}
+
+// TestBettingStructuresPlayOut plays bot hands under each structure and checks
+// the chips are conserved
+func TestBettingStructuresPlayOut(t *testing.T) {
+	for _, betting := range []BettingStructure{NO_LIMIT, POT_LIMIT, FIXED_LIMIT} {
+		t.Run(string(betting), func(t *testing.T) {
+			useFastTimers(t)
+			for seed := int64(1); seed <= 5; seed++ {
+				state := newBotTable(4, seed)
+				state.betting = betting
+				for hand := 0; hand < 10; hand++ {
+					state.RunGameLogic()
+					playHand(t, state, 500)
+					require.Equal(t, 4*STARTING_PURSE, totalChips(state), "chips conserved (seed %d, hand %d)", seed, hand)
+				}
+			}
+		})
+	}
+}
+
+// TestTablesShowBetting verifies /tables reports the betting structure of each table
+func TestTablesShowBetting(t *testing.T) {
+	origTables := tables
+	t.Cleanup(func() { tables = origTables })
+
+	for _, betting := range []BettingStructure{NO_LIMIT, POT_LIMIT, FIXED_LIMIT} {
+		tableId := fmt.Sprintf("it-bet-%s", betting)
+		createTable("Betting "+string(betting), tableId, 1, false).betting = betting
+		t.Cleanup(func() { stateMap.Delete(tableId) })
+	}
+
+	server := httptest.NewServer(setupRouter())
+	defer server.Close()
+	body, err := newSimClient(server.URL, "", "", nil).get("/tables?dev=1")
+	require.NoError(t, err)
+
+	var list []GameTable
+	require.NoError(t, json.Unmarshal(body, &list))
+	found := map[string]BettingStructure{}
+	for _, table := range list {
+		found[table.Table] = table.Betting
+	}
+	assert.Equal(t, NO_LIMIT, found["it-bet-nl"])
+	assert.Equal(t, POT_LIMIT, found["it-bet-pl"])
+	assert.Equal(t, FIXED_LIMIT, found["it-bet-fl"])
+}
@@ -171,6 +171,10 @@ type GameState struct {
 	raiseAmount   int
 	registerLobby bool
 	allowBotGames bool // tests only: allow hands with zero human players
+	betting       BettingStructure
+	variant       GameVariant
+	tournament    *Tournament // nil for a cash table
+	history       *handHistory // hand being recorded, nil if hand histories are off
 
 	buttonPos     int    // Seat index of the dealer button; rotates each hand
 	lastRaiseSize int    // Size of the last bet/raise increment this round (min-raise tracking)
@@ -180,10 +184,12 @@ type GameState struct {
 
 // Used to send a list of available tables
 type GameTable struct {
-	Table      string `json:"t"`
-	Name       string `json:"n"`
-	CurPlayers int    `json:"p"`
-	MaxPlayers int    `json:"m"`
+	Table      string           `json:"t"`
+	Name       string           `json:"n"`
+	CurPlayers int              `json:"p"`
+	MaxPlayers int              `json:"m"`
+	Betting    BettingStructure `json:"b"` // nl, pl or fl
+	Variant    GameVariant      `json:"g"` // th, om or o8
 }
 
 var initServerOnce sync.Once
@@ -235,8 +241,8 @@ func (state *GameState) shuffleDeck() {
 }
 
 func (state *GameState) dealHoleCards() {
-	// Deal 2 rounds of cards to each player, one card at a time.
-	for cardNum := 0; cardNum < 2; cardNum++ {
+	// Deal 2 rounds of cards (4 in Omaha) to each player, one card at a time.
+	for cardNum := 0; cardNum < state.variant.holeCards(); cardNum++ {
 		for i := range state.Players {
 			player := &state.Players[i]
 			if player.Status == STATUS_PLAYING {
@@ -254,6 +260,8 @@ func createGameState(playerCount int, registerLobby bool) *GameState {
 	state.Round = 0
 	state.ActivePlayer = -1
 	state.registerLobby = registerLobby
+	state.betting = NO_LIMIT
+	state.variant = HOLDEM
 	state.CommunityCards = []card{}
 	state.buttonPos = -1
 	state.rng = rand.New(rand.NewSource(time.Now().UnixNano()))
@@ -308,6 +316,21 @@ func (state *GameState) postBlind(seat int, amount int) int {
 	return amount
 }
 
+// postAnte posts up to "amount" of an ante for the given seat, going all-in if short
+func (state *GameState) postAnte(seat int, amount int) int {
+	player := &state.Players[seat]
+	if amount > player.Purse {
+		amount = player.Purse
+	}
+	player.Purse -= amount
+	player.totalBet += amount
+	state.Pot += amount
+	if player.Purse == 0 {
+		player.Status = STATUS_ALL_IN
+	}
+	return amount
+}
+
 // newRound starts a brand new hand: resets per-hand state, deals hole cards,
 // rotates the dealer button, and posts blinds.
 func (state *GameState) newRound() {
@@ -315,6 +338,13 @@ func (state *GameState) newRound() {
 	// Drop any players that left last round
 	state.dropInactivePlayers(true, false)
 
+	// A tournament is over when players leaving leaves a single one with chips
+	if result := state.updateTournament(); state.gameOver {
+		state.Winner = result
+		state.LastResult = result
+		return
+	}
+
 	if len(state.Players) < 2 {
 		return
 	}
@@ -338,8 +368,9 @@ func (state *GameState) newRound() {
 	for i := 0; i < len(state.Players); i++ {
 		player := &state.Players[i]
 
-		// A bot with under 25 chips leaves; another takes their place with a fresh purse
-		if player.isBot && player.Purse < 25 {
+		// A bot with under 25 chips leaves; another takes their place with a fresh purse.
+		// There are no rebuys in a tournament.
+		if player.isBot && player.Purse < 25 && state.tournament == nil {
 			player.Purse = STARTING_PURSE
 			for j := 0; j < len(botNames); j++ {
 				botNameUsed := false
@@ -383,9 +414,20 @@ func (state *GameState) newRound() {
 		state.LastResult = ""
 	}
 
-	// Deal 2 hole cards to each playing player
+	// Tournament blinds go up on schedule
+	if state.tournament != nil && state.tournament.nextHand() {
+		level := state.blinds()
+		state.LastResult = fmt.Sprintf("Blinds up to %d/%d", level.SmallBlind, level.BigBlind)
+		if level.Ante > 0 {
+			state.LastResult += fmt.Sprintf(", ante %d", level.Ante)
+		}
+		log.Printf("TOURNAMENT: %s", state.LastResult)
+	}
+	level := state.blinds()
+
+	// Deal the hole cards to each playing player
 	state.dealHoleCards()
-	log.Printf("CARDS: Dealt 2 hole cards to each player")
+	log.Printf("CARDS: Dealt %d hole cards to each player", state.variant.holeCards())
 
 	// Rotate the dealer button to the next playing seat
 	state.buttonPos = state.nextSeatWith(state.buttonPos, STATUS_PLAYING)
@@ -402,14 +444,30 @@ func (state *GameState) newRound() {
 		firstToAct = state.nextSeatWith(bigBlindIndex, STATUS_PLAYING)
 	}
 
-	posted := state.postBlind(smallBlindIndex, SB)
+	state.startHandHistory(smallBlindIndex, bigBlindIndex)
+
+	// Antes are dead money: they go in the pot but don't count toward the bet to call
+	if level.Ante > 0 {
+		for i := range state.Players {
+			if state.Players[i].Status == STATUS_PLAYING {
+				posted := state.postAnte(i, level.Ante)
+				state.history.post(&state.Players[i], "the ante", posted)
+			}
+		}
+		log.Printf("BLINDS: Antes of $%d posted", level.Ante)
+	}
+
+	posted := state.postBlind(smallBlindIndex, level.SmallBlind)
+	state.history.post(&state.Players[smallBlindIndex], "small blind", posted)
 	log.Printf("BLINDS: %s posts small blind $%d", state.Players[smallBlindIndex].Name, posted)
-	posted = state.postBlind(bigBlindIndex, BB)
+	posted = state.postBlind(bigBlindIndex, level.BigBlind)
+	state.history.post(&state.Players[bigBlindIndex], "big blind", posted)
 	log.Printf("BLINDS: %s posts big blind $%d", state.Players[bigBlindIndex].Name, posted)
+	state.history.holeCards(state.Players)
 
 	// The bet to match is the full big blind even if the BB posted short (all-in)
-	state.currentBet = BB
-	state.lastRaiseSize = BB
+	state.currentBet = level.BigBlind
+	state.lastRaiseSize = level.BigBlind
 
 	// If a blind poster went all-in posting, first-to-act may need recomputing
 	if state.Players[firstToAct].Status != STATUS_PLAYING {
@@ -434,7 +492,7 @@ func (state *GameState) getPlayerWithBestVisibleHand(highHand bool) int {
 	for i := 0; i < len(state.Players); i++ {
 		player := &state.Players[i]
 		if player.Status == STATUS_PLAYING || player.Status == STATUS_ALL_IN {
-			rank := getRank(player.Hand, state.CommunityCards)
+			rank := []int{int(state.variant.evalRank(player.Hand, state.CommunityCards))}
 
 			// Add player number to start of rank to hold on to when sorting
 			rank = append([]int{i}, rank...)
@@ -472,6 +530,7 @@ func (state *GameState) dealCommunityCards(count int) {
 		state.CommunityCards = append(state.CommunityCards, state.Deck[state.deckIndex])
 		state.deckIndex++
 	}
+	state.history.board(state.CommunityCards)
 }
 
 func (state *GameState) resetPlayersForNewBettingRound() {
@@ -533,7 +592,7 @@ func (state *GameState) addPlayer(playerName string, isBot bool) {
 	player := Player{
 		Name:     playerName,
 		Status:   STATUS_WAITING,
-		Purse:    STARTING_PURSE,
+		Purse:    state.startingPurse(),
 		isBot:    isBot,
 		lastPing: time.Now(),
 	}
@@ -562,8 +621,8 @@ func (state *GameState) setClientPlayerByName(playerName string) {
 		state.dropInactivePlayers(false, true)
 	}
 
-	// Add new player if there is room
-	if state.clientPlayer < 0 && len(state.Players) < 8 {
+	// Add new player if there is room (tournaments only seat players while registering)
+	if state.clientPlayer < 0 && state.seatOpen() {
 		state.addPlayer(playerName, false)
 		state.clientPlayer = len(state.Players) - 1
 
@@ -593,6 +652,11 @@ func (state *GameState) endGame(abortGame bool) {
 	for i := range state.Players {
 		state.Players[i].Bet = 0
 	}
+	if abortGame {
+		state.history = nil // an aborted hand has no history
+	} else {
+		state.returnUncalledBet()
+	}
 	log.Printf("POT: Final pot size is $%d", state.Pot)
 
 	// Find players still in the hand (including all-in players)
@@ -611,6 +675,7 @@ func (state *GameState) endGame(abortGame bool) {
 		winner := &state.Players[winnerIndex]
 		log.Printf("WINNER: %s wins by default (all others folded) - pot: $%d", winner.Name, state.Pot)
 		winner.Purse += state.Pot
+		state.history.collect(winner.Name, state.Pot, 0)
 		result = fmt.Sprintf("%s won by default", winner.Name)
 		state.wonByFolds = true
 	} else if !abortGame && len(playersInHandIndices) > 1 {
@@ -618,28 +683,30 @@ func (state *GameState) endGame(abortGame bool) {
 		log.Printf("SHOWDOWN: %d players remain for showdown", len(playersInHandIndices))
 		state.wonByFolds = false
 
-		board := ""
-		for _, card := range state.CommunityCards {
-			board += valueLookup[card.Rank] + suitLookup[card.Suit]
-		}
-		boardCards := cardrank.Must(board)
-
 		pockets := [][]cardrank.Card{}
 		playerMap := []int{} // map index in pockets back to state.Players index
 		for _, playerIndex := range playersInHandIndices {
-			player := state.Players[playerIndex]
-			hand := ""
-			for _, card := range player.Hand {
-				hand += valueLookup[card.Rank] + suitLookup[card.Suit]
-			}
-			pockets = append(pockets, cardrank.Must(hand))
+			pockets = append(pockets, toCardrank(state.Players[playerIndex].Hand))
 			playerMap = append(playerMap, playerIndex)
 		}
 
-		// Use Texas Hold'em evaluator (lower HiRank = better hand)
-		evs := cardrank.Holdem.EvalPockets(pockets, boardCards)
+		// Use the variant's evaluator (lower HiRank = better hand). Omaha
+		// evaluators only make hands with exactly 2 hole cards.
+		evs := state.variant.evalType().EvalPockets(pockets, toCardrank(state.CommunityCards))
 		order, pivot := cardrank.Order(evs, false)
 
+		state.history.showdown()
+		for pi, playerIndex := range playersInHandIndices {
+			description := strings.Split(fmt.Sprintf("%s", evs[pi].Desc(false)), " [")[0]
+			if state.variant.hiLo() {
+				description = "HI: " + description
+				if lo := evs[pi].LoRank; lo != cardrank.Invalid && lo < cardrank.EightOrBetter {
+					description += "; LO: " + lowString(evs[pi].LoBest)
+				}
+			}
+			state.history.show(&state.Players[playerIndex], description)
+		}
+
 		if pivot > 0 {
 			// Distribute the pot in layers by contribution level so all-in players
 			// only win the portion of the pot they contested (main pot + side pots)
@@ -654,6 +721,7 @@ func (state *GameState) endGame(abortGame bool) {
 
 			distributed := 0
 			prev := 0
+			pot := -1 // 0 is the main pot, then the side pots
 			var lastWinners []int
 			for _, level := range levels {
 				// Pot slice for this layer: every player (including folded) contributes
@@ -674,33 +742,24 @@ func (state *GameState) endGame(abortGame bool) {
 				}
 
 				// Winners of this layer: best hand among contenders who contributed this much
-				best := cardrank.Invalid
-				winners := []int{}
-				for pi, playerIndex := range playersInHandIndices {
-					if state.Players[playerIndex].totalBet < level {
-						continue
-					}
-					rank := evs[pi].HiRank
-					if best == cardrank.Invalid || rank < best {
-						best = rank
-						winners = []int{playerIndex}
-					} else if rank == best {
-						winners = append(winners, playerIndex)
-					}
-				}
+				winners := state.potWinners(evs, playersInHandIndices, level, false)
 				if len(winners) == 0 {
 					continue
 				}
-
-				share := slice / len(winners)
-				remainder := slice % len(winners)
-				for j, winnerIndex := range winners {
-					state.Players[winnerIndex].Purse += share
-					if j == 0 {
-						// Odd chip(s) go to the first winner so the pot always balances
-						state.Players[winnerIndex].Purse += remainder
+				pot++
+
+				// Hi/Lo: the best qualifying low among the same contenders takes half the
+				// layer (the odd chip goes to the high half). Without a low, high takes all.
+				highSlice := slice
+				if state.variant.hiLo() {
+					if lowWinners := state.potWinners(evs, playersInHandIndices, level, true); len(lowWinners) > 0 {
+						highSlice = slice - slice/2
+						state.awardChips(lowWinners, slice/2, pot)
+						log.Printf("POT: Low half of layer up to $%d ($%d) won by %v", level, slice/2, lowWinners)
 					}
 				}
+				state.awardChips(winners, highSlice, pot)
+
 				distributed += slice
 				lastWinners = winners
 				if len(levels) > 1 {
@@ -713,6 +772,7 @@ func (state *GameState) endGame(abortGame bool) {
 			leftover := state.Pot - distributed
 			if leftover > 0 && len(lastWinners) > 0 {
 				state.Players[lastWinners[0]].Purse += leftover
+				state.history.collect(state.Players[lastWinners[0]].Name, leftover, pot)
 			}
 
 			winnerNames := []string{}
@@ -724,6 +784,20 @@ func (state *GameState) endGame(abortGame bool) {
 			log.Printf("WINNER: %s wins with %s - pot: $%d", strings.Join(winnerNames, " and "), handDesc, state.Pot)
 			result = fmt.Sprintf("%s won with %s", strings.Join(winnerNames, " and "), handDesc)
 			result = strings.Split(result, " [")[0] // Clean up description
+
+			// Hi/Lo: name the best low, e.g. "Bob won low with 8-6-4-3-A"
+			if state.variant.hiLo() {
+				lowOrder, lowPivot := cardrank.Order(evs, true)
+				if lowPivot > 0 {
+					lowNames := []string{}
+					for i := 0; i < lowPivot; i++ {
+						lowNames = append(lowNames, state.Players[playerMap[lowOrder[i]]].Name)
+					}
+					result += fmt.Sprintf(", %s won low with %s", strings.Join(lowNames, " and "), lowString(evs[lowOrder[0]].LoBest))
+				} else {
+					result += ", no low"
+				}
+			}
 		} else {
 			result = "No winner could be determined in showdown."
 		}
@@ -738,15 +812,107 @@ func (state *GameState) endGame(abortGame bool) {
 		}
 	}
 
+	// Tournament eliminations (or the final result) follow the hand result
+	if !abortGame {
+		if text := state.updateTournament(); state.tournament != nil && state.tournament.phase == TOURNAMENT_FINISHED {
+			result = text
+		} else if text != "" {
+			result += ". " + text
+		}
+	}
+
 	state.Winner = result
 	state.LastResult = result
 	log.Println(result)
+	state.endHandHistory()
 
 	// Set timer for starting the next hand
 	// Always set a delay before the next round starts to allow players to see the result.
 	state.moveExpires = time.Now().Add(ENDGAME_TIME_LIMIT)
 }
 
+// potWinners returns the players with the best hand among the contenders who put
+// at least "level" chips in the pot. With low, the best qualifying (8-or-better)
+// low hand instead, none if nobody has one.
+func (state *GameState) potWinners(evs []*cardrank.Eval, contenders []int, level int, low bool) []int {
+	best := cardrank.Invalid
+	winners := []int{}
+	for pi, playerIndex := range contenders {
+		if state.Players[playerIndex].totalBet < level {
+			continue
+		}
+		rank := evs[pi].HiRank
+		if low {
+			rank = evs[pi].LoRank
+			if rank == cardrank.Invalid || rank >= cardrank.EightOrBetter {
+				continue
+			}
+		}
+		if best == cardrank.Invalid || rank < best {
+			best = rank
+			winners = []int{playerIndex}
+		} else if rank == best {
+			winners = append(winners, playerIndex)
+		}
+	}
+	return winners
+}
+
+// awardChips splits chips of a pot between the winners
+func (state *GameState) awardChips(winners []int, amount int, pot int) {
+	share := amount / len(winners)
+	remainder := amount % len(winners)
+	for j, winnerIndex := range winners {
+		won := share
+		if j == 0 {
+			// Odd chip(s) go to the first winner so the pot always balances
+			won += remainder
+		}
+		state.Players[winnerIndex].Purse += won
+		state.history.collect(state.Players[winnerIndex].Name, won, pot)
+	}
+}
+
+// returnUncalledBet gives the part of the biggest bet that nobody called back to
+// the player who made it, so it does not count as a pot won
+func (state *GameState) returnUncalledBet() {
+	top := -1
+	second := 0
+	for i, player := range state.Players {
+		if top < 0 || player.totalBet > state.Players[top].totalBet {
+			if top >= 0 {
+				second = state.Players[top].totalBet
+			}
+			top = i
+		} else if player.totalBet > second {
+			second = player.totalBet
+		}
+	}
+	if top < 0 {
+		return
+	}
+
+	player := &state.Players[top]
+	uncalled := player.totalBet - second
+	if uncalled <= 0 || (player.Status != STATUS_PLAYING && player.Status != STATUS_ALL_IN) {
+		return
+	}
+	player.totalBet -= uncalled
+	player.Purse += uncalled
+	state.Pot -= uncalled
+	state.history.uncalled(player.Name, uncalled)
+	log.Printf("POT: Uncalled bet of $%d returned to %s", uncalled, player.Name)
+}
+
+// lowString renders a low hand as its ranks, highest first, e.g. "8-6-4-3-A"
+func lowString(cards []cardrank.Card) string {
+	ranks := []string{}
+	for _, c := range cards {
+		ranks = append(ranks, c.Rank().String())
+	}
+	return strings.Join(ranks, "-")
+}
+
 // isBettingRoundComplete returns true when every player still able to act has
 // voluntarily acted this round and matched the current bet. All-in players are done
 // by definition. Also true when nobody can act (everyone all-in).
@@ -851,6 +1017,11 @@ func (state *GameState) RunGameLogic() {
 		return
 	}
 
+	// Tournament tables only deal hands while the tournament is running
+	if state.tournament != nil && !state.runTournament() {
+		return
+	}
+
 	// We can't play a game until there are at least 2 players
 	if len(state.Players) < 2 {
 		// Reset the round to 0 so the client knows there is no active game being run
@@ -1036,7 +1207,7 @@ func (state *GameState) getBotMove() string {
 
 	// Pre-flop strategy
 	if state.Round == 1 {
-		handStrength := getStartingHandStrength(player.Hand)
+		handStrength := state.variant.startingHandStrength(player.Hand)
 
 		// VPIP check: Decide if the hand is strong enough to play based on VPIP.
 		// A lower VPIP means the bot is tighter and requires a stronger hand.
@@ -1068,7 +1239,7 @@ func (state *GameState) getBotMove() string {
 	}
 
 	// Post-flop strategy: evaluate made-hand strength with the community cards
-	rank := cardrank.EvalRank(getRank(player.Hand, state.CommunityCards)[0])
+	rank := state.variant.evalRank(player.Hand, state.CommunityCards)
 	category := rank.Fixed()
 
 	// Strong hands (two pair or better): bet/raise aggressively per profile
@@ -1132,6 +1303,14 @@ func (state *GameState) dropInactivePlayers(inMiddleOfGame bool, dropForNewPlaye
 	playersWereDropped := len(state.Players) != len(players)
 
 	if playersWereDropped {
+		// A player dropped from a running tournament is out of it
+		if state.tournament != nil && state.tournament.phase == TOURNAMENT_RUNNING {
+			for _, player := range state.Players {
+				if !slices.ContainsFunc(players, func(p Player) bool { return p.Name == player.Name }) {
+					state.tournament.eliminate(player.Name)
+				}
+			}
+		}
 		state.Players = players
 	}
 
@@ -1163,6 +1342,9 @@ func (state *GameState) clientLeave() {
 	}
 	player := &state.Players[state.clientPlayer]
 
+	if player.Status == STATUS_PLAYING || player.Status == STATUS_ALL_IN {
+		state.history.leave(player)
+	}
 	player.Status = STATUS_LEFT
 	player.Move = "LEFT"
 
@@ -1211,6 +1393,8 @@ func (state *GameState) performMove(move string, internalCall ...bool) bool {
 		return false
 	}
 
+	lastBet := state.currentBet
+	betAmount := 0
 	if move == "FO" { // FOLD
 		player.Status = STATUS_FOLDED
 	} else if move == "CH" { // CHECK
@@ -1218,7 +1402,7 @@ func (state *GameState) performMove(move string, internalCall ...bool) bool {
 	} else { // CA, BL, BH, RL, RH, AI - all move chips into the pot
 		// The chip amount is derived server-side from the move code, so clients
 		// only ever send short codes (8-bit friendly)
-		betAmount := state.moveChipAmount(move)
+		betAmount = state.moveChipAmount(move)
 		if betAmount < 0 {
 			return false
 		}
@@ -1250,6 +1434,7 @@ func (state *GameState) performMove(move string, internalCall ...bool) bool {
 	}
 
 	player.actedThisRound = true
+	state.history.move(player, betAmount, lastBet)
 
 	// Assign the move string directly, or use lookup for simple moves
 	if lookup, ok := moveLookup[move]; ok {
@@ -1294,24 +1479,45 @@ func (state *GameState) nextValidPlayer() {
 //
 //	FO fold | CH check | CA call | BL bet (1xBB) | BH bet (2xBB)
 //	RL min-raise | RH bigger raise | AI all-in
+//
+// Pot-limit and fixed-limit tables size some codes differently (see betting.go)
 func (state *GameState) moveChipAmount(move string) int {
 	player := state.Players[state.ActivePlayer]
 	callAmount := state.currentBet - player.Bet
 
+	switch state.betting {
+	case POT_LIMIT:
+		// The big bet and raise are pot sized
+		if move == "BH" || move == "RH" {
+			return state.maxChipAmount()
+		}
+	case FIXED_LIMIT:
+		// A single fixed size for bets and raises
+		switch move {
+		case "BL":
+			return state.fixedBetSize()
+		case "RL":
+			return callAmount + state.fixedBetSize()
+		case "BH", "RH":
+			return -1
+		}
+	}
+
 	// Minimum raise increment is the size of the last bet/raise this round, or
 	// the big blind if there has been none
+	bigBlind := state.blinds().BigBlind
 	minRaiseIncrement := state.lastRaiseSize
-	if minRaiseIncrement < BB {
-		minRaiseIncrement = BB
+	if minRaiseIncrement < bigBlind {
+		minRaiseIncrement = bigBlind
 	}
 
 	switch move {
 	case "CA":
 		return callAmount
 	case "BL":
-		return BB
+		return bigBlind
 	case "BH":
-		return 2 * BB
+		return 2 * bigBlind
 	case "RL":
 		return callAmount + minRaiseIncrement
 	case "RH":
@@ -1343,25 +1549,28 @@ func (state *GameState) getValidMoves() []validMove {
 	}
 
 	if state.currentBet == 0 {
-		// No bet yet this round: offer the two bet sizes
-		if player.Purse >= state.moveChipAmount("BL") {
-			moves = append(moves, validMove{Move: "BL", Name: fmt.Sprintf("Bet %d", BB)})
+		// No bet yet this round: offer the two bet sizes (only one in fixed-limit)
+		bl := state.moveChipAmount("BL")
+		if player.Purse >= bl {
+			moves = append(moves, validMove{Move: "BL", Name: fmt.Sprintf("Bet %d", bl)})
 		}
-		if player.Purse >= state.moveChipAmount("BH") {
-			moves = append(moves, validMove{Move: "BH", Name: fmt.Sprintf("Bet %d", 2*BB)})
+		if bh := state.moveChipAmount("BH"); bh > bl && player.Purse >= bh {
+			moves = append(moves, validMove{Move: "BH", Name: fmt.Sprintf("Bet %d", bh)})
 		}
-	} else {
-		// A bet has been made: offer the two raise sizes
-		if rl := state.moveChipAmount("RL"); player.Purse >= rl {
+	} else if state.betting != FIXED_LIMIT || !state.fixedLimitCapped() {
+		// A bet has been made: offer the two raise sizes (one in fixed-limit, until the cap)
+		rl := state.moveChipAmount("RL")
+		if player.Purse >= rl {
 			moves = append(moves, validMove{Move: "RL", Name: fmt.Sprintf("Raise %d", rl)})
 		}
-		if rh := state.moveChipAmount("RH"); player.Purse >= rh {
+		if rh := state.moveChipAmount("RH"); rh > rl && player.Purse >= rh {
 			moves = append(moves, validMove{Move: "RH", Name: fmt.Sprintf("Raise %d", rh)})
 		}
 	}
 
-	// Always allow all-in if the player has chips
-	if player.Purse > 0 {
+	// Allow all-in if the player has chips, and in pot/fixed-limit only up to the
+	// limit (it always covers a call for less)
+	if limit := state.maxChipAmount(); player.Purse > 0 && (limit < 0 || player.Purse <= limit) {
 		moves = append(moves, validMove{Move: "AI", Name: fmt.Sprintf("All-in %d", player.Purse)})
 	}
 
@@ -1524,6 +1733,14 @@ func (state *GameState) getHumanPlayerCountInfo() (int, int) {
 	humanPlayerCount := 0
 	cutoff := time.Now().Add(PLAYER_PING_TIMEOUT)
 
+	// A tournament has its own number of seats, and no free seat once started
+	if state.tournament != nil {
+		humanAvailSlots = state.tournament.config.Seats
+		if state.tournament.phase != TOURNAMENT_REGISTERING {
+			humanAvailSlots = len(state.Players)
+		}
+	}
+
 	for _, player := range state.Players {
 		if player.isBot {
 			humanAvailSlots--
@@ -1533,27 +1750,3 @@ func (state *GameState) getHumanPlayerCountInfo() (int, int) {
 	}
 	return humanAvailSlots, humanPlayerCount
 }
-
-// getRank evaluates the best 5-card hand from 2 hole cards plus the community cards.
-// Cards use the live deck convention: Rank 2..14 (14 = Ace), Suit 0..3.
-// Returns the cardrank EvalRank as a single-element slice; LOWER is BETTER
-// (1 = royal flush, 7462 = worst high card).
-func getRank(holeCards []card, communityCards []card) []int {
-	// The evaluator needs a full 5-card hand available (2 hole + 3+ community)
-	if len(holeCards) < 2 || len(holeCards)+len(communityCards) < 5 {
-		return []int{int(cardrank.Nothing)}
-	}
-
-	// Convert to cardrank string notation (same convention as endGame showdown)
-	pocket := ""
-	for _, c := range holeCards {
-		pocket += valueLookup[c.Rank] + suitLookup[c.Suit]
-	}
-	board := ""
-	for _, c := range communityCards {
-		board += valueLookup[c.Rank] + suitLookup[c.Suit]
-	}
-
-	ev := cardrank.Holdem.Eval(cardrank.Must(pocket), cardrank.Must(board))
-	return []int{int(ev.HiRank)}
-}
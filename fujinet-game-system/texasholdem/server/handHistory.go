@@ -0,0 +1,447 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	_ "time/tzdata" // hand histories are timed in ET, also on hosts without a zone database
+)
+
+/*
+Hand histories
+
+Every completed hand is written to the hand history file of its table, in the
+PokerStars text format that poker tracking software imports: the seats and
+stacks, the blinds and antes, every action, the board by street, the showdown and
+who collected which pot.
+
+	PokerStars Hand #1792000000001:  Hold'em No Limit (5/10) - 2026/10/17 16:00:00 ET
+	Table 'The Basement' 8-max (Play Money) Seat #1 is the button
+	Seat 1: Thom (1000 in chips)
+	...
+	*** SUMMARY ***
+
+The file has the hole cards of every player ("Dealt to" lines). A PokerStars
+history is written for one player though, so /history only returns the hole
+cards of the player asking, if any.
+
+The files are kept in HandHistoryDir, one per table (<table>.txt). When one grows
+past HAND_HISTORY_MAX_BYTES it is rotated to <table>.1.txt, and so on, keeping
+HAND_HISTORY_FILES files.
+*/
+
+// HandHistoryDir is where the hand history files are written. Empty disables them.
+var HandHistoryDir string
+
+// Rotation of the hand history files. Vars so tests can shrink them.
+var HAND_HISTORY_MAX_BYTES int64 = 1024 * 1024
+var HAND_HISTORY_FILES = 5
+
+// Hands are separated by blank lines, as in PokerStars files
+const HAND_HISTORY_SEPARATOR = "\n\n\n"
+
+// Hand numbers are unique across tables and restarts: they count up from the
+// time the server started
+var lastHandId atomic.Int64
+
+func init() {
+	lastHandId.Store(time.Now().Unix() * 1000)
+}
+
+// historyMutex keeps the rotation of a file from running under a read
+var historyMutex sync.Mutex
+
+type historySeat struct {
+	name    string
+	seat    int
+	role    string // e.g. " (button)", " (small blind)"
+	summary string // how the hand ended for the player, set when it did
+}
+
+type historyCollect struct {
+	name   string
+	amount int
+	pot    int // 0 is the main pot, then the side pots
+}
+
+// handHistory records the hand being played. The methods do nothing on a nil
+// history, so the game logic records without checking if histories are on.
+type handHistory struct {
+	id       int64
+	lines    []string
+	seats    []*historySeat
+	street   string // street being played, empty pre-flop
+	collects []historyCollect
+}
+
+// startHandHistory starts the history of the hand just dealt, with the seats of
+// the players dealt in. Called after the button moved, before antes and blinds.
+func (state *GameState) startHandHistory(smallBlindIndex int, bigBlindIndex int) {
+	state.history = nil
+	if HandHistoryDir == "" {
+		return
+	}
+
+	h := &handHistory{id: lastHandId.Add(1)}
+	level := state.blinds()
+	stakes := fmt.Sprintf("(%d/%d)", level.SmallBlind, level.BigBlind)
+	if state.betting == FIXED_LIMIT {
+		// Limit games are named by their small and big bets
+		stakes = fmt.Sprintf("(%d/%d)", level.BigBlind, 2*level.BigBlind)
+	}
+	game := state.variant.name() + " " + state.betting.name()
+
+	maxSeats := 8
+	money := " (Play Money)"
+	if state.tournament != nil {
+		maxSeats = state.tournament.config.Seats
+		money = ""
+		h.add("PokerStars Hand #%d: Tournament #%d, Freeroll  %s - Level %s %s - %s",
+			h.id, state.tournament.id, game, romanNumeral(state.tournament.level+1), stakes, historyTime(time.Now()))
+	} else {
+		h.add("PokerStars Hand #%d:  %s %s - %s", h.id, game, stakes, historyTime(time.Now()))
+	}
+	h.add("Table '%s' %d-max%s Seat #%d is the button", state.serverName, maxSeats, money, state.buttonPos+1)
+
+	for i, player := range state.Players {
+		if player.Status != STATUS_PLAYING {
+			continue
+		}
+		seat := &historySeat{name: player.Name, seat: i + 1}
+		if i == state.buttonPos {
+			seat.role += " (button)"
+		}
+		if i == smallBlindIndex {
+			seat.role += " (small blind)"
+		} else if i == bigBlindIndex {
+			seat.role += " (big blind)"
+		}
+		h.seats = append(h.seats, seat)
+		h.add("Seat %d: %s (%d in chips)", seat.seat, player.Name, player.Purse)
+	}
+	state.history = h
+}
+
+func (h *handHistory) add(format string, a ...any) {
+	h.lines = append(h.lines, fmt.Sprintf(format, a...))
+}
+
+func (h *handHistory) seat(name string) *historySeat {
+	for _, seat := range h.seats {
+		if seat.name == name {
+			return seat
+		}
+	}
+	return &historySeat{name: name}
+}
+
+// post records a blind or ante posted, e.g. "small blind" or "the ante"
+func (h *handHistory) post(player *Player, blind string, amount int) {
+	if h == nil {
+		return
+	}
+	h.add("%s: posts %s %d%s", player.Name, blind, amount, allInText(player))
+}
+
+// holeCards records the hole cards dealt
+func (h *handHistory) holeCards(players []Player) {
+	if h == nil {
+		return
+	}
+	h.add("*** HOLE CARDS ***")
+	for _, player := range players {
+		if len(player.Hand) > 0 {
+			h.add("Dealt to %s %s", player.Name, historyCards(player.Hand))
+		}
+	}
+}
+
+// move records the move the player just made, which put amount chips in. lastBet
+// is the bet to call before the move.
+func (h *handHistory) move(player *Player, amount int, lastBet int) {
+	if h == nil {
+		return
+	}
+	switch {
+	case player.Status == STATUS_FOLDED:
+		h.add("%s: folds", player.Name)
+		h.folded(player)
+	case amount == 0:
+		h.add("%s: checks", player.Name)
+	case player.Bet > lastBet && lastBet == 0:
+		h.add("%s: bets %d%s", player.Name, amount, allInText(player))
+	case player.Bet > lastBet:
+		h.add("%s: raises %d to %d%s", player.Name, player.Bet-lastBet, player.Bet, allInText(player))
+	default:
+		h.add("%s: calls %d%s", player.Name, amount, allInText(player))
+	}
+}
+
+// leave records a player leaving the table during the hand, which folds the hand
+func (h *handHistory) leave(player *Player) {
+	if h == nil {
+		return
+	}
+	h.add("%s leaves the table", player.Name)
+	h.folded(player)
+}
+
+func (h *handHistory) folded(player *Player) {
+	seat := h.seat(player.Name)
+	if h.street == "" {
+		seat.summary = "folded before Flop"
+		if player.totalBet == 0 {
+			seat.summary += " (didn't bet)"
+		}
+	} else {
+		seat.summary = "folded on the " + h.street
+	}
+}
+
+// board records the community cards dealt for the next street
+func (h *handHistory) board(cards []card) {
+	if h == nil || len(cards) < 3 {
+		return
+	}
+	switch len(cards) {
+	case 3:
+		h.street = "Flop"
+		h.add("*** FLOP *** %s", historyCards(cards))
+	case 4:
+		h.street = "Turn"
+		h.add("*** TURN *** %s %s", historyCards(cards[:3]), historyCards(cards[3:]))
+	default:
+		h.street = "River"
+		h.add("*** RIVER *** %s %s", historyCards(cards[:4]), historyCards(cards[4:]))
+	}
+}
+
+// uncalled records the part of a bet nobody called going back to the player
+func (h *handHistory) uncalled(name string, amount int) {
+	if h == nil {
+		return
+	}
+	h.add("Uncalled bet (%d) returned to %s", amount, name)
+}
+
+// showdown starts the showdown
+func (h *handHistory) showdown() {
+	if h == nil {
+		return
+	}
+	h.add("*** SHOW DOWN ***")
+}
+
+// show records the hand a player shows down, and its description
+func (h *handHistory) show(player *Player, description string) {
+	if h == nil {
+		return
+	}
+	h.add("%s: shows %s (%s)", player.Name, historyCards(player.Hand), description)
+	h.seat(player.Name).summary = fmt.Sprintf("showed %s and lost with %s", historyCards(player.Hand), description)
+}
+
+// collect records chips won from a pot
+func (h *handHistory) collect(name string, amount int, pot int) {
+	if h == nil || amount == 0 {
+		return
+	}
+	h.collects = append(h.collects, historyCollect{name, amount, pot})
+}
+
+// String renders the hand, with the collected pots and the summary
+func (h *handHistory) String(board []card, wonByFolds bool) string {
+	lines := append([]string{}, h.lines...)
+	add := func(format string, a ...any) { lines = append(lines, fmt.Sprintf(format, a...)) }
+
+	pots := []int{}
+	won := map[string]int{}
+	for _, c := range h.collects {
+		for len(pots) <= c.pot {
+			pots = append(pots, 0)
+		}
+		pots[c.pot] += c.amount
+		won[c.name] += c.amount
+	}
+	potName := func(pot int) string {
+		switch {
+		case len(pots) == 1:
+			return "pot"
+		case pot == 0:
+			return "main pot"
+		}
+		return fmt.Sprintf("side pot-%d", pot)
+	}
+
+	for _, c := range h.collects {
+		add("%s collected %d from %s", c.name, c.amount, potName(c.pot))
+	}
+	if wonByFolds && len(h.collects) > 0 {
+		add("%s: doesn't show hand", h.collects[0].name)
+	}
+
+	add("*** SUMMARY ***")
+	total := 0
+	potNames := ""
+	for pot, amount := range pots {
+		total += amount
+		if len(pots) > 1 {
+			potNames += fmt.Sprintf(" %s %d.", strings.ToUpper(potName(pot)[:1])+potName(pot)[1:], amount)
+		}
+	}
+	add("Total pot %d%s | Rake 0", total, potNames)
+	if len(board) > 0 {
+		add("Board %s", historyCards(board))
+	}
+
+	for _, seat := range h.seats {
+		summary := seat.summary
+		switch {
+		case won[seat.name] > 0 && strings.HasPrefix(summary, "showed"):
+			summary = strings.Replace(summary, " and lost with ", fmt.Sprintf(" and won (%d) with ", won[seat.name]), 1)
+		case won[seat.name] > 0:
+			summary = fmt.Sprintf("collected (%d)", won[seat.name])
+		case summary == "":
+			summary = "mucked"
+		}
+		add("Seat %d: %s%s %s", seat.seat, seat.name, seat.role, summary)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// endHandHistory writes the history of the hand that just ended to the table's file
+func (state *GameState) endHandHistory() {
+	h := state.history
+	state.history = nil
+	if h == nil {
+		return
+	}
+	writeHandHistory(state.TableId, h.String(state.CommunityCards, state.wonByFolds))
+	log.Printf("HISTORY: Hand #%d written", h.id)
+}
+
+// historyFile returns the path of the table's hand history file; rotated files
+// have a number
+func historyFile(table string, number int) string {
+	if number == 0 {
+		return filepath.Join(HandHistoryDir, table+".txt")
+	}
+	return filepath.Join(HandHistoryDir, fmt.Sprintf("%s.%d.txt", table, number))
+}
+
+// writeHandHistory appends a hand to the table's file, rotating it first if full
+func writeHandHistory(table string, text string) {
+	historyMutex.Lock()
+	defer historyMutex.Unlock()
+
+	if err := os.MkdirAll(HandHistoryDir, 0755); err != nil {
+		log.Println("Error creating hand history directory:", err)
+		return
+	}
+
+	if info, err := os.Stat(historyFile(table, 0)); err == nil && info.Size() >= HAND_HISTORY_MAX_BYTES {
+		os.Remove(historyFile(table, HAND_HISTORY_FILES-1))
+		for number := HAND_HISTORY_FILES - 2; number >= 0; number-- {
+			os.Rename(historyFile(table, number), historyFile(table, number+1))
+		}
+	}
+
+	file, err := os.OpenFile(historyFile(table, 0), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Println("Error opening hand history:", err)
+		return
+	}
+	defer file.Close()
+	if _, err := file.WriteString(text + HAND_HISTORY_SEPARATOR); err != nil {
+		log.Println("Error writing hand history:", err)
+	}
+}
+
+// readHandHistory returns the hands of the table kept in its files, oldest first,
+// or only the given hand number (if not 0). Only the hole cards of "player" are
+// kept. Returns false if there is no such hand.
+func readHandHistory(table string, hand int64, player string) (string, bool) {
+	historyMutex.Lock()
+	defer historyMutex.Unlock()
+
+	hands := []string{}
+	for number := HAND_HISTORY_FILES - 1; number >= 0; number-- {
+		data, err := os.ReadFile(historyFile(table, number))
+		if err != nil {
+			continue
+		}
+		for _, text := range strings.Split(string(data), HAND_HISTORY_SEPARATOR) {
+			text = strings.TrimSpace(text)
+			if text == "" || hand != 0 && !strings.HasPrefix(text, fmt.Sprintf("PokerStars Hand #%d:", hand)) {
+				continue
+			}
+			hands = append(hands, dealtOnlyTo(text, player))
+		}
+	}
+
+	if len(hands) == 0 {
+		return "", false
+	}
+	return strings.Join(hands, HAND_HISTORY_SEPARATOR) + HAND_HISTORY_SEPARATOR, true
+}
+
+// dealtOnlyTo removes the hole cards of everyone but the player from a hand
+func dealtOnlyTo(text string, player string) string {
+	lines := []string{}
+	for _, line := range strings.Split(text, "\n") {
+		if dealt, ok := strings.CutPrefix(line, "Dealt to "); ok {
+			name, _, _ := strings.Cut(dealt, " [")
+			if player == "" || !strings.EqualFold(name, player) {
+				continue
+			}
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// historyCards writes cards the PokerStars way, e.g. "[Ah Td]"
+func historyCards(cards []card) string {
+	s := []string{}
+	for _, c := range cards {
+		s = append(s, valueLookup[c.Rank]+strings.ToLower(suitLookup[c.Suit]))
+	}
+	return "[" + strings.Join(s, " ") + "]"
+}
+
+func allInText(player *Player) string {
+	if player.Purse == 0 {
+		return " and is all-in"
+	}
+	return ""
+}
+
+// historyTime is the time of a hand in ET, as PokerStars writes it
+func historyTime(t time.Time) string {
+	if et, err := time.LoadLocation("America/New_York"); err == nil {
+		return t.In(et).Format("2006/01/02 15:04:05") + " ET"
+	}
+	return t.UTC().Format("2006/01/02 15:04:05") + " UTC"
+}
+
+// romanNumeral writes a tournament level, e.g. "IV"
+func romanNumeral(n int) string {
+	numerals := []struct {
+		value  int
+		symbol string
+	}{{50, "L"}, {40, "XL"}, {10, "X"}, {9, "IX"}, {5, "V"}, {4, "IV"}, {1, "I"}}
+	s := ""
+	for _, numeral := range numerals {
+		for n >= numeral.value {
+			s += numeral.symbol
+			n -= numeral.value
+		}
+	}
+	return s
+}
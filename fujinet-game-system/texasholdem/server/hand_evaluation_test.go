@@ -7,9 +7,9 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
-// TestGetRank verifies hand evaluation using the live deck convention
+// TestEvalRank verifies Hold'em hand evaluation using the live deck convention
 // (Rank 2..14 with Ace=14, Suit 0..3). Lower EvalRank = better hand.
-func TestGetRank(t *testing.T) {
+func TestEvalRank(t *testing.T) {
 	tests := []struct {
 		name             string
 		holeCards        []string
@@ -86,47 +86,45 @@ func TestGetRank(t *testing.T) {
 
 	// Verify each hand category and that ranks strictly worsen down the list
 	// (lower EvalRank = better hand)
-	prevRank := 0
+	prevRank := cardrank.EvalRank(0)
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			rank := getRank(parseCards(t, tt.holeCards...), parseCards(t, tt.communityCards...))
-			assert.NotEmpty(t, rank)
-			got := cardrank.EvalRank(rank[0]).Name()
-			assert.Equal(t, tt.expectedHandName, got, "hand category for %s", tt.name)
-			assert.Greater(t, rank[0], prevRank, "%s should rank worse (higher value) than the previous, stronger hand", tt.name)
-			prevRank = rank[0]
+			rank := HOLDEM.evalRank(parseCards(t, tt.holeCards...), parseCards(t, tt.communityCards...))
+			assert.Equal(t, tt.expectedHandName, rank.Name(), "hand category for %s", tt.name)
+			assert.Greater(t, rank, prevRank, "%s should rank worse (higher value) than the previous, stronger hand", tt.name)
+			prevRank = rank
 		})
 	}
 }
 
-// TestGetRankKingsAndAces guards the historical bug where cards with Rank 13 (King)
+// TestEvalRankKingsAndAces guards the historical bug where cards with Rank 13 (King)
 // and 14 (Ace) were silently dropped by the evaluator
-func TestGetRankKingsAndAces(t *testing.T) {
+func TestEvalRankKingsAndAces(t *testing.T) {
 	// Pair of aces must beat a pair of kings, which must beat a pair of queens
 	board := []string{"9C", "7D", "5H", "3S", "2C"}
-	aces := getRank(parseCards(t, "AC", "AD"), parseCards(t, board...))[0]
-	kings := getRank(parseCards(t, "KC", "KD"), parseCards(t, board...))[0]
-	queens := getRank(parseCards(t, "QC", "QD"), parseCards(t, board...))[0]
+	aces := HOLDEM.evalRank(parseCards(t, "AC", "AD"), parseCards(t, board...))
+	kings := HOLDEM.evalRank(parseCards(t, "KC", "KD"), parseCards(t, board...))
+	queens := HOLDEM.evalRank(parseCards(t, "QC", "QD"), parseCards(t, board...))
 
 	assert.Less(t, aces, kings, "pair of aces must beat pair of kings")
 	assert.Less(t, kings, queens, "pair of kings must beat pair of queens")
 
 	// All must evaluate as a pair (not high card, which happens if K/A were dropped)
-	for _, r := range []int{aces, kings, queens} {
-		assert.Equal(t, cardrank.Pair.Name(), cardrank.EvalRank(r).Name())
+	for _, r := range []cardrank.EvalRank{aces, kings, queens} {
+		assert.Equal(t, cardrank.Pair.Name(), r.Name())
 	}
 }
 
-// TestGetRankDoesNotCorruptHand guards the append-aliasing bug: evaluating a hand
+// TestEvalRankDoesNotCorruptHand guards the append-aliasing bug: evaluating a hand
 // must not mutate the caller's hole card slice
-func TestGetRankDoesNotCorruptHand(t *testing.T) {
+func TestEvalRankDoesNotCorruptHand(t *testing.T) {
 	// Build a hole-card slice with extra capacity so a naive append would write
 	// into its backing array
 	backing := make([]card, 2, 7)
 	copy(backing, parseCards(t, "AC", "KD"))
 	community := parseCards(t, "QH", "JS", "TC")
 
-	getRank(backing, community)
+	HOLDEM.evalRank(backing, community)
 
 	assert.Equal(t, parseCards(t, "AC", "KD"), backing, "hole cards must not be mutated by evaluation")
 }
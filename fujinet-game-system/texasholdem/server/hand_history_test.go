@@ -0,0 +1,235 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// useHandHistories writes the hand histories to a temporary directory
+func useHandHistories(t *testing.T) {
+	t.Helper()
+	orig := HandHistoryDir
+	HandHistoryDir = t.TempDir()
+	t.Cleanup(func() { HandHistoryDir = orig })
+}
+
+// lastHandHistory reads back the last hand written for the table, with every
+// player's hole cards
+func lastHandHistory(t *testing.T, state *GameState) string {
+	t.Helper()
+	data, err := os.ReadFile(historyFile(state.TableId, 0))
+	require.NoError(t, err)
+	hands := strings.Split(strings.TrimSpace(string(data)), HAND_HISTORY_SEPARATOR)
+	return hands[len(hands)-1]
+}
+
+// TestHandHistorySidePots checks the history of a three-way all-in against the
+// PokerStars format, down to the side pots and the uncalled bet
+func TestHandHistorySidePots(t *testing.T) {
+	useFastTimers(t)
+	useHandHistories(t)
+	state := newBotTable(3, 1)
+	state.serverName = "History Test"
+	state.Players[0].Purse = 100
+	state.Players[1].Purse = 300
+	state.Players[2].Purse = 1000
+	names := []string{state.Players[0].Name, state.Players[1].Name, state.Players[2].Name}
+
+	rigDeck(t, state,
+		[][]string{{"AC", "AD"}, {"KH", "QH"}, {"7D", "2S"}},
+		[]string{"AH", "AS", "9H", "5H", "3C"})
+	state.newRound()
+	for state.countByStatus(STATUS_PLAYING) > 0 && !state.gameOver {
+		require.True(t, state.performMove("AI", true))
+	}
+	state.RunGameLogic()
+	require.True(t, state.gameOver)
+	assert.Equal(t, []int{300, 400, 700}, []int{state.Players[0].Purse, state.Players[1].Purse, state.Players[2].Purse})
+	assert.Equal(t, 700, state.Pot, "the uncalled bet is not in the pot")
+
+	text := lastHandHistory(t, state)
+	assert.Regexp(t, regexp.MustCompile(`^PokerStars Hand #\d+:  Hold'em No Limit \(5/10\) - \d{4}/\d\d/\d\d \d\d:\d\d:\d\d ET\n`), text)
+
+	want := fmt.Sprintf(`Table 'History Test' 8-max (Play Money) Seat #1 is the button
+Seat 1: %[1]s (100 in chips)
+Seat 2: %[2]s (300 in chips)
+Seat 3: %[3]s (1000 in chips)
+%[2]s: posts small blind 5
+%[3]s: posts big blind 10
+*** HOLE CARDS ***
+Dealt to %[1]s [Ac Ad]
+Dealt to %[2]s [Kh Qh]
+Dealt to %[3]s [7d 2s]
+%[1]s: raises 90 to 100 and is all-in
+%[2]s: raises 200 to 300 and is all-in
+%[3]s: raises 700 to 1000 and is all-in
+*** FLOP *** [Ah As 9h]
+*** TURN *** [Ah As 9h] [5h]
+*** RIVER *** [Ah As 9h 5h] [3c]
+Uncalled bet (700) returned to %[3]s
+*** SHOW DOWN ***
+%[1]s: shows [Ac Ad] (Four of a Kind, Aces, kicker Nine)
+%[2]s: shows [Kh Qh] (Flush, Ace-high, kickers King, Queen, Nine, Five)
+%[3]s: shows [7d 2s] (Pair, Aces, kickers Nine, Seven, Five)
+%[1]s collected 300 from main pot
+%[2]s collected 400 from side pot-1
+*** SUMMARY ***
+Total pot 700 Main pot 300. Side pot-1 400. | Rake 0
+Board [Ah As 9h 5h 3c]
+Seat 1: %[1]s (button) showed [Ac Ad] and won (300) with Four of a Kind, Aces, kicker Nine
+Seat 2: %[2]s (small blind) showed [Kh Qh] and won (400) with Flush, Ace-high, kickers King, Queen, Nine, Five
+Seat 3: %[3]s (big blind) showed [7d 2s] and lost with Pair, Aces, kickers Nine, Seven, Five`, names[0], names[1], names[2])
+	_, body, _ := strings.Cut(text, "\n")
+	assert.Equal(t, want, body)
+}
+
+// TestHandHistoryActions checks bets, calls, checks and folds, and a hand won
+// without a showdown
+func TestHandHistoryActions(t *testing.T) {
+	useFastTimers(t)
+	useHandHistories(t)
+	state := newBotTable(3, 2)
+	state.newRound()
+	button, small, big := state.Players[0].Name, state.Players[1].Name, state.Players[2].Name
+
+	require.True(t, state.performMove("CA", true))
+	require.True(t, state.performMove("CA", true))
+	require.True(t, state.performMove("CH", true))
+	state.RunGameLogic()
+	require.Equal(t, 2, state.Round)
+	require.True(t, state.performMove("BL", true)) // small blind bets 10
+	require.True(t, state.performMove("RL", true)) // big blind raises to 20
+	require.True(t, state.performMove("FO", true))
+	require.True(t, state.performMove("FO", true))
+	state.RunGameLogic()
+	require.True(t, state.gameOver)
+
+	text := lastHandHistory(t, state)
+	lines := strings.Split(text, "\n")
+	for _, line := range []string{
+		button + ": calls 10",
+		small + ": calls 5",
+		big + ": checks",
+		small + ": bets 10",
+		big + ": raises 10 to 20",
+		button + ": folds",
+		small + ": folds",
+		"Uncalled bet (10) returned to " + big,
+		big + " collected 50 from pot",
+		big + ": doesn't show hand",
+		"Total pot 50 | Rake 0",
+		"Seat 1: " + button + " (button) folded on the Flop",
+		"Seat 3: " + big + " (big blind) collected (50)",
+	} {
+		assert.Contains(t, lines, line)
+	}
+	assert.NotContains(t, text, "SHOW DOWN")
+	assert.Equal(t, STARTING_PURSE+30, state.Players[2].Purse)
+}
+
+// TestHandHistoryHiLo checks an Omaha Hi/Lo showdown shows both hands and the
+// halves of each pot
+func TestHandHistoryHiLo(t *testing.T) {
+	useFastTimers(t)
+	useHandHistories(t)
+	state := newVariantTable(OMAHA_HI_LO, 100, 300)
+	rigDeck(t, state,
+		[][]string{{"AC", "3D", "QH", "QS"}, {"KH", "KC", "9S", "9D"}},
+		[]string{"2H", "5D", "8C", "KS", "KD"})
+	shoveAll(t, state)
+
+	text := lastHandHistory(t, state)
+	low, high := state.Players[0].Name, state.Players[1].Name
+	assert.Contains(t, text, ":  Omaha Hi/Lo No Limit (5/10) - ")
+	assert.Contains(t, text, "\nDealt to "+low+" [Ac 3d Qh Qs]\n")
+	assert.Contains(t, text, "\nUncalled bet (200) returned to "+high+"\n")
+	assert.Contains(t, text, "\n"+low+": shows [Ac 3d Qh Qs] (HI: Two Pair, Kings over Queens, kicker Eight; LO: 8-5-3-2-A)\n")
+	assert.Contains(t, text, "\n"+high+": shows [Kh Kc 9s 9d] (HI: Four of a Kind, Kings, kicker Eight)\n")
+	assert.Contains(t, text, "\n"+low+" collected 100 from pot\n"+high+" collected 100 from pot\n")
+	assert.Contains(t, text, "\nTotal pot 200 | Rake 0\n")
+}
+
+// TestHandHistoryTournament checks the tournament header and the antes
+func TestHandHistoryTournament(t *testing.T) {
+	useTournamentTimers(t)
+	useHandHistories(t)
+	state := newTournamentTable(3, 41, TournamentConfig{
+		StartingStack: 1000,
+		Levels:        []BlindLevel{{10, 20, 5}},
+	})
+	state.serverName = "Sit & Go"
+	state.RunGameLogic()
+	playHand(t, state, 200)
+
+	text := lastHandHistory(t, state)
+	assert.Contains(t, text, fmt.Sprintf(": Tournament #%d, Freeroll  Hold'em No Limit - Level I (10/20) - ", state.tournament.id))
+	assert.Contains(t, text, "\nTable 'Sit & Go' 3-max Seat #1 is the button\n")
+	assert.Contains(t, text, state.Players[0].Name+": posts the ante 5\n")
+}
+
+// TestHandHistoryDownload plays hands at a table and downloads them from /history
+func TestHandHistoryDownload(t *testing.T) {
+	useFastTimers(t)
+	useHandHistories(t)
+	origMax, origFiles := HAND_HISTORY_MAX_BYTES, HAND_HISTORY_FILES
+	t.Cleanup(func() { HAND_HISTORY_MAX_BYTES, HAND_HISTORY_FILES = origMax, origFiles })
+	HAND_HISTORY_MAX_BYTES = 1 // every hand goes to a new file
+	HAND_HISTORY_FILES = 3
+
+	const tableId = "it-history"
+	state := createTable("History", tableId, 2, false)
+	state.allowBotGames = true
+	t.Cleanup(func() { stateMap.Delete(tableId) })
+
+	ids := []string{}
+	for hand := 0; hand < 4; hand++ {
+		state.RunGameLogic()
+		playHand(t, state, 500)
+		ids = append(ids, regexp.MustCompile(`^PokerStars Hand #(\d+):`).FindStringSubmatch(lastHandHistory(t, state))[1])
+	}
+	bot := state.Players[0].Name
+
+	server := httptest.NewServer(setupRouter())
+	defer server.Close()
+	get := func(query string) (int, string) {
+		resp, err := http.Get(server.URL + "/history?" + query)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, string(body)
+	}
+
+	// The 3 files kept have the last 3 hands, oldest first, without hole cards
+	status, text := get("table=" + tableId)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, 3, strings.Count(text, "PokerStars Hand #"))
+	assert.NotContains(t, text, "#"+ids[0]+":", "the oldest file was rotated out")
+	assert.Less(t, strings.Index(text, "#"+ids[1]+":"), strings.Index(text, "#"+ids[3]+":"))
+	assert.NotContains(t, text, "Dealt to")
+
+	// One hand, with the hole cards of the player asking
+	status, text = get("table=" + tableId + "&hand=" + ids[2] + "&player=" + url.QueryEscape(strings.ToLower(bot)))
+	require.Equal(t, http.StatusOK, status)
+	assert.True(t, strings.HasPrefix(text, "PokerStars Hand #"+ids[2]+":"))
+	assert.Equal(t, 1, strings.Count(text, "PokerStars Hand #"))
+	assert.Equal(t, 1, strings.Count(text, "Dealt to "))
+	assert.Contains(t, text, "Dealt to "+bot+" [")
+
+	status, _ = get("table=" + tableId + "&hand=" + ids[0])
+	assert.Equal(t, http.StatusNotFound, status)
+	status, _ = get("table=" + tableId + "&hand=abc")
+	assert.Equal(t, http.StatusBadRequest, status)
+	status, _ = get("table=nosuchtable")
+	assert.Equal(t, http.StatusNotFound, status)
+}
@@ -5,6 +5,8 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"net/url"
+	"time"
 
 	"github.com/goccy/go-json"
 )
@@ -32,6 +34,11 @@ var DefaultGameServerDetails = GameServer{
 
 var UpdateLobby bool
 
+// LobbyAnnounceUrl receives the tournament results (and starts) of the tables in
+// the lobby, as a form POST of serverurl and text - e.g. the POST /game endpoint
+// of a cherry chat server, which tells the table's game room. Empty disables it.
+var LobbyAnnounceUrl string
+
 type GameServer struct {
 	// Properties being sent from Game Server
 	Game       string       `json:"game"`
@@ -98,3 +105,31 @@ func sendStateToLobby(maxPlayers int, curPlayers int, isOnline bool, server stri
 	}
 
 }
+
+// sendResultToLobby reports something that happened at a table (e.g. a tournament
+// result) to LobbyAnnounceUrl
+func sendResultToLobby(server string, instanceUrlSuffix string, text string) {
+
+	if !UpdateLobby || LobbyAnnounceUrl == "" {
+		return
+	}
+
+	form := url.Values{
+		"serverurl": {DefaultGameServerDetails.Serverurl + instanceUrlSuffix},
+		"text":      {text},
+	}
+	log.Printf("Announcing to the lobby for %s: %s", server, text)
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	response, err := client.PostForm(LobbyAnnounceUrl, form)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode > 300 {
+		body, _ := io.ReadAll(response.Body)
+		log.Printf("Lobby announce response: %s %s", response.Status, string(body))
+	}
+}
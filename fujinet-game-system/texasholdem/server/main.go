@@ -4,7 +4,9 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -18,7 +20,6 @@ import (
 // A mutex is used so a given table can only be accessed by a single request at a time
 var stateMap sync.Map
 var tables []GameTable = []GameTable{}
-var runningHubs sync.Map
 
 var tableMutex KeyedMutex
 
@@ -46,6 +47,11 @@ func main() {
 
 	// Set environment flags
 	UpdateLobby = os.Getenv("GO_PROD") == "1" && !disableLobby
+	LobbyAnnounceUrl = os.Getenv("LOBBY_ANNOUNCE_URL")
+	HandHistoryDir = os.Getenv("HAND_HISTORY_DIR")
+	if HandHistoryDir == "" {
+		HandHistoryDir = "hand_history"
+	}
 
 	if UpdateLobby {
 		log.Printf("This instance will update the lobby at " + LOBBY_ENDPOINT_UPSERT)
@@ -98,6 +104,7 @@ func setupRouter() *gin.Engine {
 	router.GET("/tables", apiTables)
 	router.GET("/version", apiVersion)
 	router.GET("/updateLobby", apiUpdateLobby)
+	router.GET("/history", apiHistory)
 
 	router.GET("/ws", func(c *gin.Context) {
 		serveWs(c.Writer, c.Request)
@@ -121,24 +128,29 @@ func setupRouter() *gin.Engine {
 
 // Executes a move for the client player, if that player is currently active
 func apiMove(c *gin.Context) {
+	client, _ := playerMove(c.Query("table"), c.Query("player"), c.Param("move"))
+	notifyHub(c.Query("table"))
+	serializeResults(c, client)
+}
 
-	state, unlock := getState(c)
-	var client *clientState
-	func() {
-		defer unlock()
+// playerMove performs the move for the player (seating them if needed, as /move
+// always has) and returns their updated view. The move is only made if the
+// player is the active player; ok reports whether it was made and accepted.
+func playerMove(table string, player string, move string) (client *clientState, ok bool) {
+	state, unlock := getTableState(table, player)
+	defer unlock()
 
-		if state != nil {
-			// Access check - only move if the client is the active player
-			if state.clientPlayer == state.ActivePlayer {
-				move := strings.ToUpper(c.Param("move"))
-				state.performMove(move)
-				saveState(state)
-			}
-			client = state.createClientState()
-		}
-	}()
+	if state == nil {
+		return nil, false
+	}
 
-	serializeResults(c, client)
+	// Access check - only move if the client is the active player
+	if state.clientPlayer >= 0 && state.clientPlayer == state.ActivePlayer {
+		ok = state.performMove(strings.ToUpper(move))
+		saveState(state)
+	}
+
+	return state.createClientState(), ok
 }
 
 // Steps forward and returns the updated state
@@ -158,6 +170,7 @@ func apiState(c *gin.Context) {
 			client = state.createClientState()
 		}
 	}()
+	notifyHub(c.Query("table"))
 
 	// Check if passed in hash matches the state
 	if client != nil && len(hash) > 0 && hash == client.Hash {
@@ -183,6 +196,7 @@ func apiLeave(c *gin.Context) {
 			}
 		}
 	}()
+	notifyHub(c.Query("table"))
 	serializeResults(c, "bye")
 }
 
@@ -216,6 +230,8 @@ func apiTables(c *gin.Context) {
 				humanPlayerSlots, humanPlayerCount := state.getHumanPlayerCountInfo()
 				table.CurPlayers = humanPlayerCount
 				table.MaxPlayers = humanPlayerSlots
+				table.Betting = state.betting
+				table.Variant = state.variant
 				tableOutput = append(tableOutput, table)
 			}
 		}
@@ -241,17 +257,57 @@ func apiUpdateLobby(c *gin.Context) {
 	serializeResults(c, "Lobby Updated")
 }
 
+// Returns the hand histories of a table as a PokerStars text file: all the hands
+// kept, or one with hand=N. Only the hole cards of "player" are included.
+func apiHistory(c *gin.Context) {
+	value, ok := stateMap.Load(tableKey(c.Query("table")))
+	if !ok {
+		c.String(http.StatusNotFound, "table not found")
+		return
+	}
+	table := value.(*GameState).TableId
+
+	var hand int64
+	if c.Query("hand") != "" {
+		var err error
+		if hand, err = strconv.ParseInt(c.Query("hand"), 10, 64); err != nil || hand <= 0 {
+			c.String(http.StatusBadRequest, "invalid hand")
+			return
+		}
+	}
+
+	text, found := readHandHistory(table, hand, c.Query("player"))
+	if !found {
+		c.String(http.StatusNotFound, "hand not found")
+		return
+	}
 
+	filename := table + ".txt"
+	if hand != 0 {
+		filename = fmt.Sprintf("%s-%d.txt", table, hand)
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(text))
+}
 
 // Gets the current game state for the specified table and adds the player id of the client to it
 func getState(c *gin.Context) (*GameState, func()) {
-	table := c.Query("table")
+	return getTableState(c.Query("table"), c.Query("player"))
+}
 
+// tableKey is the id a table is stored under: case insensitive, "default" if empty
+func tableKey(table string) string {
 	if table == "" {
 		table = "default"
 	}
-	table = strings.ToLower(table)
-	player := c.Query("player")
+	return strings.ToLower(table)
+}
+
+// getTableState locks the table and returns its state with the player seated as
+// the client player (an observer if player is empty). State is nil for an unknown
+// table. The caller must call unlock.
+func getTableState(table string, player string) (*GameState, func()) {
+	table = tableKey(table)
 
 	// Lock by the table so to avoid multiple threads updating the same table state
 	unlock := tableMutex.Lock(table)
@@ -266,12 +322,8 @@ func getState(c *gin.Context) (*GameState, func()) {
 		state = value.(*GameState)
 		state.setClientPlayerByName(player)
 
-		// Start the hub.run goroutine for this table if it's not already running.
-		// Pass the authoritative state pointer so the hub modifies the correct object.
-		if _, loaded := runningHubs.LoadOrStore(table, true); !loaded {
-			go hub.run(state, saveState)
-			log.Printf("Started game logic hub for table: %s", table)
-		}
+		// Start the hub for this table if it's not already running
+		hubFor(table)
 	}
 
 	return state, unlock
@@ -289,6 +341,17 @@ func initializeTables() {
 	createTable("AI Room - 2 bots", "ai2", 2, true)
 	createTable("AI Room - 4 bots", "ai4", 4, true)
 	createTable("AI Room - 6 bots", "ai6", 6, true)
+	createTable("Pot Limit - 3 bots", "pl3", 3, true).betting = POT_LIMIT
+	createTable("Fixed Limit - 3 bots", "fl3", 3, true).betting = FIXED_LIMIT
+	plo := createTable("Pot Limit Omaha - 3 bots", "plo", 3, true)
+	plo.betting, plo.variant = POT_LIMIT, OMAHA
+	omaha8 := createTable("Omaha Hi/Lo - 3 bots", "omaha8", 3, true)
+	omaha8.betting, omaha8.variant = FIXED_LIMIT, OMAHA_HI_LO
+
+	// Tournament tables: a sit-and-go that starts when its 6 seats are taken
+	// (4 by bots), and the weekly event
+	createTable("Sit & Go - 6 seats", "sng", 4, true).setTournament(SIT_AND_GO)
+	createTable("Weekly Tournament", "weekly", 0, true).setTournament(weeklyTournament())
 
 	// For client developers, create hidden tables for each # of bots (for ease of testing with a specific # of players in the game)
 	// These will not update the lobby
@@ -297,19 +360,31 @@ func initializeTables() {
 		createTable(fmt.Sprintf("Dev Room - %d bots", i), fmt.Sprintf("dev%d", i), i, false)
 	}
 
+	// Tell the lobby about the tables only now that they are configured, as a
+	// tournament changes the number of seats
+	for _, table := range tables {
+		value, _ := stateMap.Load(table.Table)
+		state := value.(*GameState)
+
+		if state.registerLobby {
+			state.updateLobby()
+
+			if UpdateLobby {
+				time.Sleep(time.Millisecond * time.Duration(100))
+			}
+		}
+	}
+
 }
 
-func createTable(serverName string, table string, botCount int, registerLobby bool) {
+func createTable(serverName string, table string, botCount int, registerLobby bool) *GameState {
 	state := createGameState(botCount, registerLobby)
 	state.TableId = table
 	stateMap.Store(table, state)
 	state.serverName = serverName
 	saveState(state)
-	state.updateLobby()
 
 	tables = append([]GameTable{{Table: table, Name: serverName}}, tables...)
 
-	if UpdateLobby {
-		time.Sleep(time.Millisecond * time.Duration(100))
-	}
+	return state
 }
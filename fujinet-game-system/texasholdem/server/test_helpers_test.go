@@ -80,8 +80,8 @@ func parseCards(t *testing.T, strs ...string) []card {
 }
 
 // rigDeck installs a test deck so the next hand deals exactly the given hole cards
-// and community cards. holeCards[i] is the 2-card hand for the i-th PLAYING seat
-// (in seat order). Deal order matches dealHoleCards: one card per player per pass.
+// and community cards. holeCards[i] is the hand for the i-th PLAYING seat (in seat
+// order), 2 cards or 4 for Omaha. Deal order matches dealHoleCards: one card per player per pass.
 func rigDeck(t *testing.T, state *GameState, holeCards [][]string, community []string) {
 	t.Helper()
 	deck := []card{}
@@ -96,10 +96,11 @@ func rigDeck(t *testing.T, state *GameState, holeCards [][]string, community []s
 	}
 
 	// Hole cards are dealt one card at a time, one pass per card number
-	for cardNum := 0; cardNum < 2; cardNum++ {
+	cardsPerHand := state.variant.holeCards()
+	for cardNum := 0; cardNum < cardsPerHand; cardNum++ {
 		for _, hand := range holeCards {
-			if len(hand) != 2 {
-				t.Fatalf("each rigged hand needs exactly 2 cards, got %v", hand)
+			if len(hand) != cardsPerHand {
+				t.Fatalf("each rigged hand needs exactly %d cards, got %v", cardsPerHand, hand)
 			}
 			add(hand[cardNum])
 		}
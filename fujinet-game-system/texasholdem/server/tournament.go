@@ -0,0 +1,446 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+/*
+Tournament (sit-and-go) tables
+
+A tournament table takes registrations (players simply join the table, as on a
+cash table) until it starts: a sit-and-go starts as soon as all its seats are
+taken, a scheduled event (e.g. the weekly tournament) starts at its start time
+with whoever registered. Everyone starts with the same stack, there are no
+rebuys, and the blinds (and antes) go up following a schedule, every so many
+hands or minutes. A player with no chips left is eliminated; the table finishes
+when one player holds all the chips, and the prize pool is paid to the top
+places. The result shows in LastResult for a while, then registration opens for
+the next tournament.
+*/
+
+// BlindLevel is a step of the blind schedule
+type BlindLevel struct {
+	SmallBlind int
+	BigBlind   int
+	Ante       int // posted by every player dealt in, before the blinds
+}
+
+// TournamentConfig describes the tournament run on a table
+type TournamentConfig struct {
+	Seats         int           // a sit-and-go starts when this many players are seated (max 8)
+	StartingStack int           // chips each player starts with
+	BuyIn         int           // prize points per entrant; the prize pool is BuyIn x entrants
+	Levels        []BlindLevel  // blind schedule, the last level stays until the end
+	HandsPerLevel int           // blinds go up every N hands (0 to use LevelDuration only)
+	LevelDuration time.Duration // blinds go up every duration (0 to use HandsPerLevel only)
+	Payouts       []int         // percent of the prize pool paid to 1st, 2nd, ...
+	Start         time.Time     // scheduled start. Zero makes a sit-and-go.
+	Every         time.Duration // repeats a scheduled start (e.g. weekly). Zero runs it once.
+}
+
+type tournamentPhase int
+
+const (
+	TOURNAMENT_REGISTERING tournamentPhase = 0
+	TOURNAMENT_RUNNING     tournamentPhase = 1
+	TOURNAMENT_FINISHED    tournamentPhase = 2
+)
+
+// How long the result of a finished tournament is shown before registration
+// opens again. A var so tests can zero it.
+var TOURNAMENT_RESULT_TIME = time.Minute * time.Duration(2)
+
+// TournamentResult is the finishing place of a player
+type TournamentResult struct {
+	Place int
+	Name  string
+	Prize int
+}
+
+type Tournament struct {
+	config       TournamentConfig
+	id           int64 // numbered like the hands, for the hand histories
+	phase        tournamentPhase
+	start        time.Time // next scheduled start (zero for a sit-and-go)
+	entrants     int
+	level        int       // index in config.Levels
+	levelHands   int       // hands dealt at the current level
+	levelStarted time.Time // when the current level started
+	eliminated   []string  // in elimination order, first out first
+	results      []TournamentResult
+	finished     time.Time
+}
+
+// DEFAULT_BLIND_LEVELS is the blind schedule of the standard tournaments
+var DEFAULT_BLIND_LEVELS = []BlindLevel{
+	{10, 20, 0},
+	{15, 30, 0},
+	{25, 50, 5},
+	{50, 100, 10},
+	{75, 150, 15},
+	{100, 200, 25},
+	{150, 300, 25},
+	{200, 400, 50},
+	{300, 600, 75},
+	{500, 1000, 100},
+}
+
+// SIT_AND_GO is a 6 seat tournament that starts as soon as it is full
+var SIT_AND_GO = TournamentConfig{
+	Seats:         6,
+	StartingStack: 1500,
+	BuyIn:         100,
+	Levels:        DEFAULT_BLIND_LEVELS,
+	HandsPerLevel: 10,
+	Payouts:       []int{65, 35},
+}
+
+// weeklyTournament is the community's weekly event, Saturdays at 20:00 UTC
+func weeklyTournament() TournamentConfig {
+	return TournamentConfig{
+		Seats:         8,
+		StartingStack: 3000,
+		BuyIn:         100,
+		Levels:        DEFAULT_BLIND_LEVELS,
+		LevelDuration: time.Minute * time.Duration(10),
+		Payouts:       []int{50, 30, 20},
+		Start:         nextWeekday(time.Now().UTC(), time.Saturday, 20),
+		Every:         time.Hour * time.Duration(24*7),
+	}
+}
+
+// nextWeekday returns the next weekday at hour:00 UTC strictly after now
+func nextWeekday(now time.Time, weekday time.Weekday, hour int) time.Time {
+	next := time.Date(now.Year(), now.Month(), now.Day(), hour, 0, 0, 0, time.UTC)
+	for next.Weekday() != weekday || !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+func newTournament(config TournamentConfig) *Tournament {
+	if config.Seats < 2 || config.Seats > 8 {
+		config.Seats = 8
+	}
+	if len(config.Levels) == 0 {
+		config.Levels = []BlindLevel{{SB, BB, 0}}
+	}
+	return &Tournament{config: config, start: config.Start}
+}
+
+// setTournament turns the table into a tournament table, open for registration
+func (state *GameState) setTournament(config TournamentConfig) {
+	state.tournament = newTournament(config)
+	for i := range state.Players {
+		state.Players[i].Purse = config.StartingStack
+	}
+	state.LastResult = state.tournament.registrationMessage(len(state.Players))
+}
+
+// blinds returns the blinds and ante in play: the current level of a running
+// tournament, or the fixed cash game blinds
+func (state *GameState) blinds() BlindLevel {
+	if state.tournament == nil {
+		return BlindLevel{SB, BB, 0}
+	}
+	return state.tournament.config.Levels[state.tournament.level]
+}
+
+// startingPurse is what a player sits down with
+func (state *GameState) startingPurse() int {
+	if state.tournament == nil {
+		return STARTING_PURSE
+	}
+	return state.tournament.config.StartingStack
+}
+
+// seatOpen tells whether a new player can sit down. A tournament only seats
+// players while registering, up to its seats.
+func (state *GameState) seatOpen() bool {
+	if len(state.Players) >= 8 {
+		return false
+	}
+	if state.tournament == nil {
+		return true
+	}
+	return state.tournament.phase == TOURNAMENT_REGISTERING && len(state.Players) < state.tournament.config.Seats
+}
+
+func (t *Tournament) registrationMessage(registered int) string {
+	if t.start.IsZero() {
+		return fmt.Sprintf("Sit & Go: %d/%d registered, starts when full", registered, t.config.Seats)
+	}
+	return fmt.Sprintf("Tournament starts %s. %d/%d registered", t.start.Format("Mon Jan 2 15:04 MST"), registered, t.config.Seats)
+}
+
+// runTournament moves the tournament through its phases. Returns true if
+// hands can be dealt.
+func (state *GameState) runTournament() bool {
+	t := state.tournament
+
+	switch t.phase {
+	case TOURNAMENT_REGISTERING:
+		now := time.Now()
+
+		// Nobody showed up for a scheduled event: move it to the next date
+		if !t.start.IsZero() && !now.Before(t.start) && len(state.Players) < 2 && t.config.Every > 0 {
+			for !t.start.After(now) {
+				t.start = t.start.Add(t.config.Every)
+			}
+		}
+
+		full := len(state.Players) >= t.config.Seats
+		due := !t.start.IsZero() && !now.Before(t.start) && len(state.Players) >= 2
+		if !full && !due {
+			state.Round = 0
+			state.ActivePlayer = -1
+			state.LastResult = t.registrationMessage(len(state.Players))
+			return false
+		}
+
+		state.startTournament()
+		return true
+
+	case TOURNAMENT_FINISHED:
+		// A one-off scheduled event keeps showing its result
+		if !t.start.IsZero() && t.config.Every == 0 {
+			return false
+		}
+		if time.Since(t.finished) < TOURNAMENT_RESULT_TIME {
+			return false
+		}
+		state.resetTournament()
+		return false
+	}
+
+	return true
+}
+
+// startTournament closes registration and seats everyone with a fresh stack
+func (state *GameState) startTournament() {
+	t := state.tournament
+
+	for i := range state.Players {
+		state.Players[i].Purse = t.config.StartingStack
+		state.Players[i].Status = STATUS_WAITING
+	}
+
+	t.phase = TOURNAMENT_RUNNING
+	t.id = lastHandId.Add(1)
+	t.entrants = len(state.Players)
+	t.level = 0
+	t.levelHands = 0
+	t.levelStarted = time.Now()
+	t.eliminated = nil
+	t.results = nil
+
+	state.Round = 0
+	state.buttonPos = -1
+	level := state.blinds()
+	state.LastResult = fmt.Sprintf("Tournament started with %d players. Blinds %d/%d", t.entrants, level.SmallBlind, level.BigBlind)
+	log.Printf("TOURNAMENT %s: started with %d players", state.TableId, t.entrants)
+
+	state.updateLobby()
+	state.announceToLobby(state.LastResult)
+}
+
+// resetTournament opens the registration of the next tournament. Everyone
+// still seated is registered.
+func (state *GameState) resetTournament() {
+	t := state.tournament
+
+	state.dropInactivePlayers(false, false)
+
+	for i := range state.Players {
+		state.Players[i].Purse = t.config.StartingStack
+		state.Players[i].Status = STATUS_WAITING
+		state.Players[i].Hand = []card{}
+		state.Players[i].Bet = 0
+		state.Players[i].totalBet = 0
+		state.Players[i].Move = ""
+	}
+
+	if !t.start.IsZero() {
+		for now := time.Now(); !t.start.After(now); {
+			t.start = t.start.Add(t.config.Every)
+		}
+	}
+
+	t.phase = TOURNAMENT_REGISTERING
+	t.level = 0
+	t.levelHands = 0
+
+	state.Round = 0
+	state.Pot = 0
+	state.gameOver = false
+	state.ActivePlayer = -1
+	state.Winner = ""
+	state.CommunityCards = []card{}
+	state.LastResult = t.registrationMessage(len(state.Players))
+
+	state.updateLobby()
+}
+
+// nextHand counts a new hand at the current level, going up a level when the
+// schedule says so. Returns true if the blinds went up.
+func (t *Tournament) nextHand() bool {
+	up := false
+
+	if t.level < len(t.config.Levels)-1 {
+		byHands := t.config.HandsPerLevel > 0 && t.levelHands >= t.config.HandsPerLevel
+		byTime := t.config.LevelDuration > 0 && time.Since(t.levelStarted) >= t.config.LevelDuration
+
+		if byHands || byTime {
+			t.level++
+			t.levelHands = 0
+			t.levelStarted = time.Now()
+			up = true
+		}
+	}
+
+	t.levelHands++
+	return up
+}
+
+// eliminate records players out of the tournament. Players busted in the same
+// hand are passed in order, the one who started the hand with fewer chips first.
+func (t *Tournament) eliminate(names ...string) {
+	for _, name := range names {
+		if !slices.Contains(t.eliminated, name) {
+			t.eliminated = append(t.eliminated, name)
+			log.Printf("TOURNAMENT: %s eliminated in place %d", name, t.entrants-len(t.eliminated)+1)
+		}
+	}
+}
+
+// updateTournament records the players busted in the hand that just ended and
+// finishes the tournament when one player is left. Returns the text to add to
+// the hand result: who is out, or the final result.
+func (state *GameState) updateTournament() string {
+	t := state.tournament
+	if t == nil || t.phase != TOURNAMENT_RUNNING {
+		return ""
+	}
+
+	// Players who went bust this hand. All their chips went in, so totalBet
+	// is what they started the hand with.
+	busted := []Player{}
+	for _, player := range state.Players {
+		if player.Purse == 0 && !slices.Contains(t.eliminated, player.Name) {
+			busted = append(busted, player)
+		}
+	}
+	sort.SliceStable(busted, func(i, j int) bool { return busted[i].totalBet < busted[j].totalBet })
+
+	out := []string{}
+	for _, player := range busted {
+		t.eliminate(player.Name)
+		out = append(out, fmt.Sprintf("%s out in %s", player.Name, ordinal(t.entrants-len(t.eliminated)+1)))
+	}
+
+	alive := []string{}
+	for _, player := range state.Players {
+		if player.Purse > 0 && !slices.Contains(t.eliminated, player.Name) {
+			alive = append(alive, player.Name)
+		}
+	}
+
+	if len(alive) <= 1 {
+		return state.finishTournament(alive)
+	}
+
+	return strings.Join(out, ", ")
+}
+
+// finishTournament pays the prize pool and ends the tournament. alive holds the
+// winner (empty if everyone left).
+func (state *GameState) finishTournament(alive []string) string {
+	t := state.tournament
+
+	places := append([]string{}, alive...)
+	for i := len(t.eliminated) - 1; i >= 0; i-- {
+		places = append(places, t.eliminated[i])
+	}
+
+	t.results = payouts(places, t.config.BuyIn*t.entrants, t.config.Payouts)
+	t.phase = TOURNAMENT_FINISHED
+	t.finished = time.Now()
+
+	state.gameOver = true
+	state.ActivePlayer = -1
+	state.Round = 5
+
+	result := "Tournament over"
+	if len(t.results) > 0 && len(alive) > 0 {
+		result = fmt.Sprintf("%s won the tournament (%d)", t.results[0].Name, t.results[0].Prize)
+		others := []string{}
+		for _, r := range t.results[1:] {
+			if r.Prize > 0 {
+				others = append(others, fmt.Sprintf("%s %s (%d)", ordinal(r.Place), r.Name, r.Prize))
+			}
+		}
+		if len(others) > 0 {
+			result += ". " + strings.Join(others, ", ")
+		}
+	}
+
+	log.Printf("TOURNAMENT %s: %s", state.TableId, result)
+	state.announceToLobby(result)
+
+	return result
+}
+
+// payouts splits the prize pool between the places (winner first) following the
+// percentages. Rounding leftovers go to the winner.
+func payouts(places []string, pool int, percents []int) []TournamentResult {
+	results := make([]TournamentResult, len(places))
+	paid := 0
+	for i, name := range places {
+		results[i] = TournamentResult{Place: i + 1, Name: name}
+		if i < len(percents) {
+			results[i].Prize = pool * percents[i] / 100
+			paid += results[i].Prize
+		}
+	}
+
+	// With fewer players than paid places, their percentages go to the winner too
+	if len(results) > 0 {
+		total := 0
+		for _, percent := range percents {
+			total += percent
+		}
+		results[0].Prize += pool*total/100 - paid
+	}
+	return results
+}
+
+// ordinal returns 1st, 2nd, 3rd, 4th...
+func ordinal(n int) string {
+	suffix := "th"
+	switch {
+	case n%100 >= 11 && n%100 <= 13:
+	case n%10 == 1:
+		suffix = "st"
+	case n%10 == 2:
+		suffix = "nd"
+	case n%10 == 3:
+		suffix = "rd"
+	}
+	return fmt.Sprintf("%d%s", n, suffix)
+}
+
+// announceToLobby reports a tournament event (start, result) of this table to
+// the lobby, for tables registered in it. It is called with the table locked,
+// so the lobby is not waited for.
+func (state *GameState) announceToLobby(text string) {
+	if !state.registerLobby {
+		return
+	}
+	go sendResultToLobby(state.serverName, "?table="+state.TableId, text)
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// useTournamentTimers makes finished tournaments reopen registration at once
+func useTournamentTimers(t *testing.T) {
+	t.Helper()
+	useFastTimers(t)
+	orig := TOURNAMENT_RESULT_TIME
+	TOURNAMENT_RESULT_TIME = 0
+	t.Cleanup(func() { TOURNAMENT_RESULT_TIME = orig })
+}
+
+// newTournamentTable creates a bot-only tournament table, full so it starts on
+// the first call to RunGameLogic
+func newTournamentTable(botCount int, seed int64, config TournamentConfig) *GameState {
+	state := newBotTable(botCount, seed)
+	config.Seats = botCount
+	state.setTournament(config)
+	return state
+}
+
+// TestTournamentBlindScheduleAndAntes verifies the blinds follow the schedule and
+// antes are posted as dead money
+func TestTournamentBlindScheduleAndAntes(t *testing.T) {
+	useTournamentTimers(t)
+	state := newTournamentTable(3, 41, TournamentConfig{
+		StartingStack: 1000,
+		Levels:        []BlindLevel{{5, 10, 0}, {10, 20, 5}},
+		HandsPerLevel: 1,
+	})
+
+	state.RunGameLogic()
+	require.Equal(t, TOURNAMENT_RUNNING, state.tournament.phase)
+	require.Equal(t, 1, state.Round, "the first hand is dealt when the tournament starts")
+	assert.Equal(t, 5+10, state.Pot, "level 1 blinds, no ante")
+	assert.Equal(t, 10, state.currentBet)
+
+	playHand(t, state, 200)
+	state.RunGameLogic()
+	require.Equal(t, 1, state.Round)
+
+	playing := state.countByStatus(STATUS_PLAYING, STATUS_ALL_IN)
+	assert.Equal(t, 1, state.tournament.level, "blinds go up after one hand")
+	assert.Equal(t, playing*5+10+20, state.Pot, "antes plus level 2 blinds")
+	assert.Equal(t, 20, state.currentBet, "antes do not count toward the bet to call")
+	assert.True(t, strings.HasPrefix(state.LastResult, "Blinds up to 10/20, ante 5"), state.LastResult)
+
+	// Raises are sized from the current big blind
+	assert.Equal(t, 20-state.Players[state.ActivePlayer].Bet+20, state.moveChipAmount("RL"))
+
+	// The last level stays
+	playHand(t, state, 200)
+	state.RunGameLogic()
+	assert.Equal(t, 1, state.tournament.level)
+}
+
+// TestSitAndGoPlaysToTheEnd plays bot sit-and-gos until one player has all the
+// chips, then checks the finishing order and the payouts
+func TestSitAndGoPlaysToTheEnd(t *testing.T) {
+	useTournamentTimers(t)
+
+	for _, seed := range []int64{1, 2, 3} {
+		state := newTournamentTable(4, seed, TournamentConfig{
+			StartingStack: 200,
+			BuyIn:         100,
+			Levels:        []BlindLevel{{25, 50, 0}, {50, 100, 10}, {100, 200, 25}},
+			HandsPerLevel: 2,
+			Payouts:       []int{60, 40},
+		})
+
+		for i := 0; i < 20000 && state.tournament.phase != TOURNAMENT_FINISHED; i++ {
+			state.RunGameLogic()
+			require.Equal(t, 4*200, totalChips(state), "chips conserved (seed %d)", seed)
+		}
+		require.Equal(t, TOURNAMENT_FINISHED, state.tournament.phase, "seed %d", seed)
+
+		results := state.tournament.results
+		require.Len(t, results, 4)
+		assert.Len(t, state.tournament.eliminated, 3)
+		for i, result := range results {
+			assert.Equal(t, i+1, result.Place)
+		}
+		assert.Equal(t, []int{240, 160, 0, 0}, []int{results[0].Prize, results[1].Prize, results[2].Prize, results[3].Prize})
+		assert.Equal(t, results[3].Name, state.tournament.eliminated[0], "first out finishes last")
+
+		winner := results[0].Name
+		for _, p := range state.Players {
+			if p.Name == winner {
+				assert.Equal(t, 800, p.Purse, "the winner has all the chips")
+			} else {
+				assert.Equal(t, 0, p.Purse)
+			}
+		}
+		assert.Equal(t, winner+" won the tournament (240). 2nd "+results[1].Name+" (160)", state.LastResult)
+		assert.Equal(t, 5, state.Round)
+
+		// The result stays while finished, then registration opens again and,
+		// the table being full, the next sit-and-go starts
+		state.RunGameLogic()
+		assert.Equal(t, TOURNAMENT_REGISTERING, state.tournament.phase)
+		for _, p := range state.Players {
+			assert.Equal(t, 200, p.Purse)
+		}
+		state.RunGameLogic()
+		assert.Equal(t, TOURNAMENT_RUNNING, state.tournament.phase)
+		assert.Equal(t, 1, state.Round)
+	}
+}
+
+// TestTournamentRegistration verifies a sit-and-go seats players only until it
+// is full, and starts then
+func TestTournamentRegistration(t *testing.T) {
+	useTournamentTimers(t)
+	state := newBotTable(1, 43)
+	state.allowBotGames = false
+	state.setTournament(TournamentConfig{Seats: 3, StartingStack: 500})
+	assert.Equal(t, 500, state.Players[0].Purse, "seated players get the starting stack")
+
+	state.setClientPlayerByName("Alice")
+	require.Equal(t, 1, state.clientPlayer)
+	assert.Equal(t, 500, state.Players[1].Purse)
+
+	state.RunGameLogic()
+	assert.Equal(t, 0, state.Round, "no hand before the table is full")
+	assert.Equal(t, "Sit & Go: 2/3 registered, starts when full", state.LastResult)
+	slots, _ := state.getHumanPlayerCountInfo()
+	assert.Equal(t, 2, slots, "3 seats, one taken by a bot")
+
+	state.setClientPlayerByName("Bob")
+	require.Equal(t, 2, state.clientPlayer)
+	state.RunGameLogic()
+	assert.Equal(t, TOURNAMENT_RUNNING, state.tournament.phase)
+	assert.Equal(t, 3, state.tournament.entrants)
+	assert.Equal(t, 1, state.Round)
+
+	// Registration is closed: a late player only watches
+	state.setClientPlayerByName("Carol")
+	assert.Equal(t, -1, state.clientPlayer)
+	assert.Len(t, state.Players, 3)
+	slots, _ = state.getHumanPlayerCountInfo()
+	assert.Equal(t, 2, slots, "no free seats once started")
+}
+
+// TestScheduledTournament verifies a scheduled event waits for its start time
+// and then starts with whoever registered
+func TestScheduledTournament(t *testing.T) {
+	useTournamentTimers(t)
+	state := newBotTable(2, 44)
+	state.setTournament(TournamentConfig{
+		Seats:         8,
+		StartingStack: 1000,
+		Start:         time.Now().Add(time.Hour),
+		Every:         time.Hour * 24 * 7,
+	})
+
+	state.RunGameLogic()
+	assert.Equal(t, TOURNAMENT_REGISTERING, state.tournament.phase)
+	assert.Contains(t, state.LastResult, "Tournament starts ")
+	assert.Contains(t, state.LastResult, "2/8 registered")
+
+	state.tournament.start = time.Now().Add(-time.Second)
+	state.RunGameLogic()
+	assert.Equal(t, TOURNAMENT_RUNNING, state.tournament.phase)
+	assert.Equal(t, 2, state.tournament.entrants)
+}
+
+func TestPayouts(t *testing.T) {
+	tests := []struct {
+		places   []string
+		pool     int
+		percents []int
+		want     []int
+	}{
+		{[]string{"A", "B", "C", "D"}, 600, []int{50, 30, 20}, []int{300, 180, 120, 0}},
+		{[]string{"A", "B"}, 200, []int{50, 30, 20}, []int{140, 60}},         // unpaid places go to the winner
+		{[]string{"A", "B", "C"}, 100, []int{34, 33, 33}, []int{34, 33, 33}}, // all paid
+		{[]string{"A", "B", "C"}, 10, []int{65, 35}, []int{7, 3, 0}},         // rounding goes to the winner
+	}
+
+	for _, test := range tests {
+		results := payouts(test.places, test.pool, test.percents)
+		prizes := []int{}
+		for _, r := range results {
+			prizes = append(prizes, r.Prize)
+		}
+		assert.Equal(t, test.want, prizes, "payouts(%v, %d, %v)", test.places, test.pool, test.percents)
+	}
+
+	for n, want := range map[int]string{1: "1st", 2: "2nd", 3: "3rd", 4: "4th", 11: "11th", 12: "12th", 21: "21st"} {
+		assert.Equal(t, want, ordinal(n))
+	}
+}
+
+func TestNextWeekday(t *testing.T) {
+	friday := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2026, 10, 17, 20, 0, 0, 0, time.UTC), nextWeekday(friday, time.Saturday, 20))
+
+	saturdayLate := time.Date(2026, 10, 17, 21, 0, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2026, 10, 24, 20, 0, 0, 0, time.UTC), nextWeekday(saturdayLate, time.Saturday, 20))
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"sort"
+
+	"github.com/cardrank/cardrank"
+)
+
+/*
+Game variants
+
+Every table plays one variant. They share the betting rounds and the wire
+protocol; only the number of hole cards, the hand evaluation and the pot split
+differ.
+
+	Texas Hold'em  2 hole cards, best 5 of the 7 cards
+	Omaha          4 hole cards, best 5 using exactly 2 hole cards and 3 from the board
+	Omaha Hi/Lo    as Omaha, the pot is split between the best high hand and the
+	               best 8-or-better low hand (the high hand takes it all when
+	               nobody has a low). The low also uses exactly 2 hole cards.
+*/
+
+type GameVariant string
+
+const (
+	HOLDEM      GameVariant = "th"
+	OMAHA       GameVariant = "om"
+	OMAHA_HI_LO GameVariant = "o8"
+)
+
+// name is the variant as hand histories write it
+func (v GameVariant) name() string {
+	switch v {
+	case OMAHA:
+		return "Omaha"
+	case OMAHA_HI_LO:
+		return "Omaha Hi/Lo"
+	}
+	return "Hold'em"
+}
+
+// holeCards is the number of hole cards dealt to each player
+func (v GameVariant) holeCards() int {
+	if v == OMAHA || v == OMAHA_HI_LO {
+		return 4
+	}
+	return 2
+}
+
+// hiLo tells whether the pot is split between the high and the low hand
+func (v GameVariant) hiLo() bool {
+	return v == OMAHA_HI_LO
+}
+
+// evalType is the cardrank evaluator of the variant
+func (v GameVariant) evalType() cardrank.Type {
+	switch v {
+	case OMAHA:
+		return cardrank.Omaha
+	case OMAHA_HI_LO:
+		return cardrank.OmahaHiLo
+	}
+	return cardrank.Holdem
+}
+
+// evalRank evaluates the best high hand of the hole cards with the community
+// cards. LOWER is BETTER; Nothing until there are enough cards to make 5.
+func (v GameVariant) evalRank(holeCards []card, communityCards []card) cardrank.EvalRank {
+	// The evaluator needs a full 5-card hand available (2 hole + 3+ community),
+	// and Omaha needs 3 of them from the board
+	if len(holeCards) < 2 || len(holeCards)+len(communityCards) < 5 {
+		return cardrank.Nothing
+	}
+	if v != HOLDEM && len(communityCards) < 3 {
+		return cardrank.Nothing
+	}
+
+	ev := v.evalType().Eval(toCardrank(holeCards), toCardrank(communityCards))
+	return ev.HiRank
+}
+
+// toCardrank converts cards to cardrank cards (same convention as the wire format)
+func toCardrank(cards []card) []cardrank.Card {
+	s := ""
+	for _, c := range cards {
+		s += valueLookup[c.Rank] + suitLookup[c.Suit]
+	}
+	if s == "" {
+		return nil
+	}
+	return cardrank.Must(s)
+}
+
+// startingHandStrength rates the hole cards for the bots, from 0 to 10 where 10
+// is the best, on the same scale for every variant
+func (v GameVariant) startingHandStrength(cards []card) int {
+	if v == HOLDEM {
+		return getStartingHandStrength(cards)
+	}
+	return getOmahaStartingHandStrength(cards, v.hiLo())
+}
+
+// getOmahaStartingHandStrength evaluates the quality of a four-card Omaha starting
+// hand. Omaha hands run close, so what counts is how the 4 cards work together:
+// big pairs, suits (ideally with the ace), connected ranks and, for Hi/Lo, an
+// ace with a small card. Returns a score from 0 to 10, where 10 is the best.
+func getOmahaStartingHandStrength(cards []card, hiLo bool) int {
+	if len(cards) != 4 {
+		return 0
+	}
+
+	score := 0
+	counts := map[int]int{}
+	suits := map[int][]card{}
+	for _, c := range cards {
+		counts[c.Rank]++
+		suits[c.Suit] = append(suits[c.Suit], c)
+	}
+
+	// Pairs: the best one counts most, a second one adds. Trips are mostly dead.
+	pairs := []int{}
+	for rank, n := range counts {
+		if n == 2 {
+			pairs = append(pairs, rank)
+		} else if n >= 3 {
+			score -= 2
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(pairs)))
+	if len(pairs) > 0 {
+		switch {
+		case pairs[0] == 14:
+			score += 4 // AA
+		case pairs[0] == 13:
+			score += 3 // KK
+		case pairs[0] >= 11:
+			score += 2 // QQ, JJ
+		default:
+			score += 1
+		}
+		if len(pairs) > 1 {
+			score++
+		}
+	}
+
+	// Suits: two cards of a suit make a flush draw, more than two waste cards
+	suited := 0
+	for _, sc := range suits {
+		if len(sc) == 2 {
+			suited++
+			for _, c := range sc {
+				if c.Rank == 14 {
+					score++ // nut flush draw
+				}
+			}
+		}
+	}
+	score += suited // single suited +1, double suited +2
+
+	// Connectedness: distinct ranks within a 5 rank window make straights
+	ranks := []int{}
+	for rank := range counts {
+		ranks = append(ranks, rank)
+	}
+	sort.Ints(ranks)
+	if len(ranks) == 4 && ranks[3]-ranks[0] <= 4 {
+		score += 3 // rundown, e.g. JT98
+	} else if len(ranks) >= 3 && (ranks[2]-ranks[0] <= 4 || len(ranks) == 4 && ranks[3]-ranks[1] <= 4) {
+		score++
+	}
+
+	// Big cards make the best high hands
+	high := 0
+	for _, c := range cards {
+		if c.Rank >= 10 {
+			high++
+		}
+	}
+	if high >= 3 {
+		score++
+	}
+
+	// Hi/Lo: an ace with a 2 or 3 is the key to the low half of the pot
+	if hiLo && counts[14] > 0 {
+		switch {
+		case counts[2] > 0:
+			score += 3
+		case counts[3] > 0:
+			score += 2
+		}
+		small := 0
+		for rank := range counts {
+			if rank <= 5 {
+				small++
+			}
+		}
+		if small >= 3 {
+			score++
+		}
+	}
+
+	if score < 0 {
+		return 0
+	}
+	if score > 10 {
+		return 10
+	}
+	return score
+}
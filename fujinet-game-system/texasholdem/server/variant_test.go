@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cardrank/cardrank"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// shoveAll deals the rigged hand and moves everyone all-in, then plays it out
+func shoveAll(t *testing.T, state *GameState) {
+	t.Helper()
+	state.newRound()
+	for state.countByStatus(STATUS_PLAYING) > 0 && !state.gameOver {
+		require.True(t, state.performMove("AI", true))
+		if state.ActivePlayer < 0 {
+			break
+		}
+	}
+	state.RunGameLogic()
+	require.True(t, state.gameOver, "hand must complete after everyone is all-in")
+}
+
+// newVariantTable creates a bot table of the variant with the given stacks
+func newVariantTable(variant GameVariant, purses ...int) *GameState {
+	state := newBotTable(len(purses), 61)
+	state.variant = variant
+	for i, purse := range purses {
+		state.Players[i].Purse = purse
+	}
+	return state
+}
+
+// TestOmahaUsesExactlyTwoHoleCards verifies an Omaha hand is made of exactly 2
+// hole cards and 3 board cards
+func TestOmahaUsesExactlyTwoHoleCards(t *testing.T) {
+	useFastTimers(t)
+	board := parseCards(t, "AH", "KH", "QH", "2H", "3C")
+
+	// One heart in the hand makes a flush in Hold'em but not in Omaha
+	hand := parseCards(t, "JH", "9C", "8D", "7S")
+	assert.Equal(t, cardrank.Flush, HOLDEM.evalRank(hand[:2], board).Fixed())
+	assert.Equal(t, cardrank.HighCard, OMAHA.evalRank(hand, board).Fixed())
+
+	// Four of the board cards cannot be played with one hole card either
+	assert.Equal(t, cardrank.Nothing, OMAHA.evalRank(hand, board[:2]), "Omaha needs 3 board cards")
+
+	state := newVariantTable(OMAHA, 1000, 1000)
+	rigDeck(t, state,
+		[][]string{{"JH", "9C", "8D", "7S"}, {"2C", "2D", "5S", "6S"}},
+		[]string{"AH", "KH", "QH", "2H", "3C"})
+	shoveAll(t, state)
+
+	for _, p := range state.Players {
+		assert.Len(t, p.Hand, 4)
+	}
+	assert.Equal(t, 0, state.Players[0].Purse, "a single suited hole card makes no flush")
+	assert.Equal(t, 2000, state.Players[1].Purse, "trips win")
+	assert.Contains(t, state.Winner, state.Players[1].Name+" won with Three of a Kind")
+}
+
+// TestOmahaHiLoSplitPots verifies the pot is split between the best high and the
+// best qualifying low, layer by layer through the side pots
+func TestOmahaHiLoSplitPots(t *testing.T) {
+	useFastTimers(t)
+
+	t.Run("Side_Pots", func(t *testing.T) {
+		state := newVariantTable(OMAHA_HI_LO, 100, 300, 1000)
+
+		// Seat 0 has the nut low, seat 1 quad kings and no low, seat 2 a worse low
+		rigDeck(t, state,
+			[][]string{{"AC", "3D", "QH", "QS"}, {"KH", "KC", "9S", "9D"}, {"4C", "6H", "JS", "JD"}},
+			[]string{"2H", "5D", "8C", "KS", "KD"})
+		shoveAll(t, state)
+
+		// Main pot 300: high 150 to seat 1, low 150 to seat 0
+		// Side pot 400: high 200 to seat 1, low 200 to seat 2 (seat 0 is not in it)
+		// Excess 700: only seat 2 put it in
+		assert.Equal(t, 150, state.Players[0].Purse, "the low takes half of the main pot")
+		assert.Equal(t, 350, state.Players[1].Purse, "the high takes half of both pots")
+		assert.Equal(t, 900, state.Players[2].Purse, "the second best low takes half of the side pot")
+		assert.Equal(t, 1400, totalChips(state), "chips conserved")
+
+		assert.Contains(t, state.Winner, state.Players[1].Name+" won with Four of a Kind")
+		assert.Contains(t, state.Winner, ", "+state.Players[0].Name+" won low with 8-5-3-2-A")
+	})
+
+	t.Run("Odd_Chip_Goes_High", func(t *testing.T) {
+		state := newVariantTable(OMAHA_HI_LO, 101, 101, 101)
+		rigDeck(t, state,
+			[][]string{{"AC", "3D", "QH", "QS"}, {"KH", "KC", "9S", "9D"}, {"7H", "7S", "JC", "JH"}},
+			[]string{"2H", "5D", "8C", "KS", "KD"})
+		shoveAll(t, state)
+
+		assert.Equal(t, 151, state.Players[0].Purse, "low half")
+		assert.Equal(t, 152, state.Players[1].Purse, "high half and the odd chip")
+		assert.Equal(t, 0, state.Players[2].Purse)
+	})
+
+	t.Run("No_Low_High_Scoops", func(t *testing.T) {
+		state := newVariantTable(OMAHA_HI_LO, 100, 100, 100)
+
+		// Only one low card on the board: nobody can make an 8-or-better low
+		rigDeck(t, state,
+			[][]string{{"AC", "3D", "QH", "QS"}, {"KH", "KC", "9S", "9D"}, {"4C", "6H", "JS", "JD"}},
+			[]string{"KS", "KD", "QC", "9H", "2S"})
+		shoveAll(t, state)
+
+		assert.Equal(t, 0, state.Players[0].Purse)
+		assert.Equal(t, 300, state.Players[1].Purse, "the high hand takes the whole pot")
+		assert.Equal(t, 0, state.Players[2].Purse)
+		assert.Contains(t, state.Winner, ", no low")
+	})
+}
+
+// TestOmahaStartingHandStrength verifies the bots rate Omaha hands by how the 4
+// cards work together, and value the low in Hi/Lo
+func TestOmahaStartingHandStrength(t *testing.T) {
+	hand := func(cards ...string) []card { return parseCards(t, cards...) }
+
+	aakkDoubleSuited := OMAHA.startingHandStrength(hand("AS", "KS", "AH", "KH"))
+	rundown := OMAHA.startingHandStrength(hand("JS", "TS", "9H", "8H"))
+	rainbowTrips := OMAHA.startingHandStrength(hand("7C", "2D", "2H", "2S"))
+	assert.Greater(t, aakkDoubleSuited, rundown)
+	assert.Greater(t, rundown, rainbowTrips)
+	assert.Equal(t, 10, aakkDoubleSuited)
+	assert.Equal(t, 0, rainbowTrips)
+
+	a2 := hand("AS", "2S", "4H", "9D")
+	assert.Greater(t, OMAHA_HI_LO.startingHandStrength(a2), OMAHA.startingHandStrength(a2), "A2 is worth more with a low")
+
+	// Hold'em hands keep their own rating
+	assert.Equal(t, getStartingHandStrength(hand("AS", "AH")), HOLDEM.startingHandStrength(hand("AS", "AH")))
+	assert.Equal(t, 0, OMAHA.startingHandStrength(hand("AS", "AH")))
+}
+
+// TestOmahaPlaysOut plays bot hands of each Omaha variant and checks the chips
+// are conserved and the hands are shown with 4 cards. Pot-limit Omaha busts bots,
+// so the chips of a replaced bot are accounted for.
+func TestOmahaPlaysOut(t *testing.T) {
+	for _, variant := range []GameVariant{OMAHA, OMAHA_HI_LO} {
+		t.Run(string(variant), func(t *testing.T) {
+			useFastTimers(t)
+			for seed := int64(1); seed <= 5; seed++ {
+				state := newBotTable(4, seed)
+				state.variant = variant
+				state.betting = POT_LIMIT
+				chips := 4 * STARTING_PURSE
+				for hand := 0; hand < 10; hand++ {
+					for _, p := range state.Players {
+						if p.Purse < 25 {
+							chips += STARTING_PURSE - p.Purse
+						}
+					}
+					state.RunGameLogic()
+					require.Len(t, state.Players[0].Hand, 4)
+					view := state.viewFor(state.Players[0].Name)
+					assert.Len(t, view.Players[0].Hand, 8, "own hand")
+					playHand(t, state, 500)
+					require.Equal(t, chips, totalChips(state), "chips conserved (seed %d, hand %d)", seed, hand)
+				}
+			}
+		})
+	}
+}
+
+// TestTablesShowVariant verifies /tables reports the variant of each table
+func TestTablesShowVariant(t *testing.T) {
+	origTables := tables
+	t.Cleanup(func() { tables = origTables })
+
+	for _, variant := range []GameVariant{HOLDEM, OMAHA, OMAHA_HI_LO} {
+		tableId := fmt.Sprintf("it-var-%s", variant)
+		createTable("Variant "+string(variant), tableId, 1, false).variant = variant
+		t.Cleanup(func() { stateMap.Delete(tableId) })
+	}
+
+	server := httptest.NewServer(setupRouter())
+	defer server.Close()
+	body, err := newSimClient(server.URL, "", "", nil).get("/tables?dev=1")
+	require.NoError(t, err)
+
+	var list []GameTable
+	require.NoError(t, json.Unmarshal(body, &list))
+	found := map[string]GameVariant{}
+	for _, table := range list {
+		found[table.Table] = table.Variant
+	}
+	assert.Equal(t, HOLDEM, found["it-var-th"])
+	assert.Equal(t, OMAHA, found["it-var-om"])
+	assert.Equal(t, OMAHA_HI_LO, found["it-var-o8"])
+}
@@ -4,29 +4,75 @@ import (
 	"encoding/json"
 	"log"
 	"net/http"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
 )
 
-// Hub maintains the set of active clients and broadcasts messages to the clients.
+// Hub maintains the sockets bound to one table and pushes each of them its own
+// client-centric view of the state whenever that view changes.
 type Hub struct {
+	table      string
 	clients    map[*Client]bool
-	broadcast  chan []byte
 	register   chan *Client
 	unregister chan *Client
+	changed    chan struct{} // the state changed outside the hub (HTTP or socket move)
+	reply      chan hubReply // a message for a single client (e.g. a rejected move)
 }
 
-func newHub() *Hub {
+type hubReply struct {
+	client  *Client
+	message []byte
+}
+
+// WebSocketMessage is the envelope of every frame the server sends
+type WebSocketMessage struct {
+	Type string      `json:"type"`
+	Data interface{} `json:"data"`
+}
+
+// hubs holds the running hub of each table, keyed by table id
+var hubs sync.Map
+
+func newHub(table string) *Hub {
 	return &Hub{
-		broadcast:  make(chan []byte),
+		table:      table,
+		clients:    make(map[*Client]bool),
 		register:   make(chan *Client),
 		unregister: make(chan *Client),
-		clients:    make(map[*Client]bool),
+		changed:    make(chan struct{}, 1),
+		reply:      make(chan hubReply, 16),
+	}
+}
+
+// hubFor returns the hub of the table, starting it the first time it is asked for
+func hubFor(table string) *Hub {
+	value, loaded := hubs.LoadOrStore(table, newHub(table))
+	h := value.(*Hub)
+	if !loaded {
+		go h.run()
+		log.Printf("Started game logic hub for table: %s", table)
+	}
+	return h
+}
+
+// notifyHub tells the table's hub (if any) to push the sockets whose view changed.
+// Never blocks - a pending notification already covers this change.
+func notifyHub(table string) {
+	value, ok := hubs.Load(tableKey(table))
+	if !ok {
+		return
+	}
+	select {
+	case value.(*Hub).changed <- struct{}{}:
+	default:
 	}
 }
 
-func (h *Hub) run(state *GameState, saveState func(*GameState)) {
+func (h *Hub) run() {
 	ticker := time.NewTicker(2 * time.Second)
 	defer ticker.Stop()
 
@@ -34,68 +80,106 @@ func (h *Hub) run(state *GameState, saveState func(*GameState)) {
 		select {
 		case client := <-h.register:
 			h.clients[client] = true
-			log.Printf("Client registered. Total clients: %d", len(h.clients))
+			log.Printf("Client %s registered at table %s. Total clients: %d", client.player, h.table, len(h.clients))
+			h.push()
 
 		case client := <-h.unregister:
-			if _, ok := h.clients[client]; ok {
-				delete(h.clients, client)
-				close(client.send)
-				log.Printf("Client unregistered. Total clients: %d", len(h.clients))
-			}
+			h.remove(client)
 
-		case message := <-h.broadcast:
-			for client := range h.clients {
-				select {
-				case client.send <- message:
-				default:
-					close(client.send)
-					delete(h.clients, client)
-				}
+		case <-h.changed:
+			h.push()
+
+		case r := <-h.reply:
+			if h.clients[r.client] {
+				h.send(r.client, r.message)
 			}
 
 		case <-ticker.C:
-			if !hubTickerEnabled {
-				continue
-			}
-			// This case handles the periodic game state update.
-			// All state access (including createClientState) must happen under the
-			// table mutex - HTTP handlers mutate the same state concurrently.
-			unlock := tableMutex.Lock(state.TableId)
-			state.RunGameLogic()
-			saveState(state)
-			state.clientPlayer = -1
-			clientState := state.createClientState()
-			unlock()
-
-			type WebSocketMessage struct {
-				Type string      `json:"type"`
-				Data interface{} `json:"data"`
-			}
-			msgToSend := WebSocketMessage{
-				Type: "gameState",
-				Data: clientState,
+			// The periodic tick drives the game logic (bot moves, timeouts, dealing)
+			// for tables that only have socket clients
+			if hubTickerEnabled {
+				h.tick()
 			}
+			h.push()
+		}
+	}
+}
 
-			message, err := json.Marshal(msgToSend)
-			if err != nil {
-				log.Printf("Error marshaling game state: %v", err)
-				continue
-			}
+func (h *Hub) tick() {
+	value, ok := stateMap.Load(h.table)
+	if !ok {
+		return
+	}
+	unlock := tableMutex.Lock(h.table)
+	defer unlock()
+	state := value.(*GameState)
+	state.clientPlayer = -1
+	state.RunGameLogic()
+	saveState(state)
+}
 
-			// Now broadcast this message
-			for client := range h.clients {
-				select {
-				case client.send <- message:
-				default:
-					close(client.send)
-					delete(h.clients, client)
-				}
-			}
+// push sends every client whose view hash changed its view of the state.
+// All state access (including createClientState) must happen under the
+// table mutex - HTTP handlers mutate the same state concurrently.
+func (h *Hub) push() {
+	if len(h.clients) == 0 {
+		return
+	}
+	value, ok := stateMap.Load(h.table)
+	if !ok {
+		return
+	}
+	state := value.(*GameState)
+
+	views := make(map[*Client]*clientState, len(h.clients))
+	unlock := tableMutex.Lock(h.table)
+	for client := range h.clients {
+		views[client] = state.viewFor(client.player)
+	}
+	unlock()
+
+	for client, view := range views {
+		if view.Hash == client.hash {
+			continue
+		}
+		message, err := json.Marshal(WebSocketMessage{Type: "gameState", Data: view})
+		if err != nil {
+			log.Printf("Error marshaling game state: %v", err)
+			continue
 		}
+		client.hash = view.Hash
+		h.send(client, message)
+	}
+}
+
+// send queues a message for a client, dropping the client if it can't keep up
+func (h *Hub) send(client *Client, message []byte) {
+	select {
+	case client.send <- message:
+	default:
+		h.remove(client)
+	}
+}
+
+func (h *Hub) remove(client *Client) {
+	if _, ok := h.clients[client]; ok {
+		delete(h.clients, client)
+		close(client.send)
+		log.Printf("Client %s unregistered from table %s. Total clients: %d", client.player, h.table, len(h.clients))
 	}
 }
 
-var hub = newHub()
+// viewFor creates the view of the state for a player already seated at the table
+// (or the observer view if not seated). Unlike setClientPlayerByName it never
+// seats anyone; a connected socket counts as a ping so its seat is kept.
+func (state *GameState) viewFor(player string) *clientState {
+	state.clientPlayer = -1
+	if len(player) > 0 {
+		state.clientPlayer = slices.IndexFunc(state.Players, func(p Player) bool { return strings.EqualFold(p.Name, player) })
+	}
+	state.playerPing()
+	return state.createClientState()
+}
 
 // hubTickerEnabled can be set to false in tests to stop the periodic hub tick from
 // driving game logic in the background
@@ -109,14 +193,22 @@ var upgrader = websocket.Upgrader{
 	},
 }
 
-// Client represents a single WebSocket connection
+// Client represents a single WebSocket connection, bound to a table and a seat
 type Client struct {
-	hub  *Hub
-	conn *websocket.Conn
-	send chan []byte
+	hub    *Hub
+	conn   *websocket.Conn
+	send   chan []byte
+	player string // empty for an observer
+	hash   string // hash of the last view sent, owned by the hub goroutine
 }
 
-// readPump pumps messages from the WebSocket connection to the hub.
+// socketMove is the frame a client sends to make a move, e.g. {"move":"CA"}
+type socketMove struct {
+	Move string `json:"move"`
+}
+
+// readPump reads the moves of the client and applies them to the table with the
+// same access checks as /move.
 func (c *Client) readPump() {
 	defer func() {
 		c.hub.unregister <- c
@@ -134,12 +226,32 @@ func (c *Client) readPump() {
 			}
 			break
 		}
-		// For now, just echo messages back to all clients
-		c.hub.broadcast <- message
+
+		var frame socketMove
+		if err := json.Unmarshal(message, &frame); err != nil || len(frame.Move) != 2 {
+			c.error(`expected {"move":"XX"}`)
+			continue
+		}
+
+		if _, ok := playerMove(c.hub.table, c.player, frame.Move); !ok {
+			c.error("move " + strings.ToUpper(frame.Move) + " not allowed")
+			continue
+		}
+		notifyHub(c.hub.table)
+	}
+}
+
+// error sends an error frame to this client only
+func (c *Client) error(text string) {
+	message, _ := json.Marshal(WebSocketMessage{Type: "error", Data: text})
+	select {
+	case c.hub.reply <- hubReply{client: c, message: message}:
+	default: // the hub is busy; the client will see the state did not change
 	}
 }
 
-// writePump pumps messages from the hub to the WebSocket connection.
+// writePump pumps messages from the hub to the WebSocket connection, one frame
+// per message.
 func (c *Client) writePump() {
 	ticker := time.NewTicker(pingPeriod)
 	defer func() {
@@ -151,20 +263,10 @@ func (c *Client) writePump() {
 		case message, ok := <-c.send:
 			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
 			if !ok {
-			c.conn.WriteMessage(websocket.CloseMessage, []byte{})
-				return
-			}
-			w, err := c.conn.NextWriter(websocket.TextMessage)
-			if err != nil {
+				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
 				return
 			}
-			w.Write(message)
-			n := len(c.send)
-			for i := 0; i < n; i++ {
-				w.Write(newline)
-				w.Write(<-c.send)
-			}
-			if err := w.Close(); err != nil {
+			if err := c.conn.WriteMessage(websocket.TextMessage, message); err != nil {
 				return
 			}
 		case <-ticker.C:
@@ -183,20 +285,31 @@ const (
 	maxMessageSize = 512
 )
 
-var (
-	newline = []byte{'\n'}
-	space   = []byte{' '}
-)
-
-// serveWs handles websocket requests from the peer.
+// serveWs binds a websocket to /ws?table=T&player=P, seating the player as /state
+// would. Without a player the socket observes the table.
 func serveWs(w http.ResponseWriter, r *http.Request) {
+	table := tableKey(r.URL.Query().Get("table"))
+	player := r.URL.Query().Get("player")
+
+	state, unlock := getTableState(table, player)
+	if state != nil {
+		saveState(state)
+	}
+	unlock()
+
+	if state == nil {
+		http.Error(w, "table not found", http.StatusNotFound)
+		return
+	}
+
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Println(err)
 		return
 	}
-	client := &Client{hub: hub, conn: conn, send: make(chan []byte, 256)}
-	hub.register <- client
+	h := hubFor(table)
+	client := &Client{hub: h, conn: conn, send: make(chan []byte, 256), player: player}
+	h.register <- client
 
 	go client.writePump()
 	go client.readPump()
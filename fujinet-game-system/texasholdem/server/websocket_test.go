@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// wsClient is a socket bound to a table seat, with its frames read in the background
+type wsClient struct {
+	conn   *websocket.Conn
+	frames chan WebSocketMessage
+}
+
+func dialTable(t *testing.T, base, table, player string) *wsClient {
+	t.Helper()
+	u := "ws" + strings.TrimPrefix(base, "http") + "/ws?table=" + url.QueryEscape(table) + "&player=" + url.QueryEscape(player)
+	conn, _, err := websocket.DefaultDialer.Dial(u, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	ws := &wsClient{conn: conn, frames: make(chan WebSocketMessage, 256)}
+	go func() {
+		defer close(ws.frames)
+		for {
+			var frame struct {
+				Type string          `json:"type"`
+				Data json.RawMessage `json:"data"`
+			}
+			if err := conn.ReadJSON(&frame); err != nil {
+				return
+			}
+			msg := WebSocketMessage{Type: frame.Type}
+			if frame.Type == "gameState" {
+				view := clientStateView{}
+				json.Unmarshal(frame.Data, &view)
+				msg.Data = view
+			} else {
+				var text string
+				json.Unmarshal(frame.Data, &text)
+				msg.Data = text
+			}
+			ws.frames <- msg
+		}
+	}()
+	return ws
+}
+
+// next returns the next frame of the given type, skipping others
+func (ws *wsClient) next(t *testing.T, frameType string) WebSocketMessage {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case msg, ok := <-ws.frames:
+			require.True(t, ok, "socket closed waiting for %s", frameType)
+			if msg.Type == frameType {
+				return msg
+			}
+		case <-timeout:
+			t.Fatalf("no %s frame received", frameType)
+		}
+	}
+}
+
+// state returns the next pushed view that satisfies match, stepping the table's
+// game logic (the hub ticker is off in tests) while waiting
+func (ws *wsClient) state(t *testing.T, tableId string, match func(clientStateView) bool) clientStateView {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		select {
+		case msg, ok := <-ws.frames:
+			require.True(t, ok, "socket closed waiting for a state")
+			if view, isState := msg.Data.(clientStateView); isState && match(view) {
+				return view
+			}
+		case <-time.After(20 * time.Millisecond):
+			withTable(tableId, func(state *GameState) {
+				state.clientPlayer = -1
+				state.RunGameLogic()
+			})
+			notifyHub(tableId)
+		}
+	}
+	t.Fatalf("no matching state pushed on table %s", tableId)
+	return clientStateView{}
+}
+
+func TestWebSocketPlayerViewsAndMoves(t *testing.T) {
+	useIntegrationTimers(t)
+	server, tableId := newHTTPTable(t, 0, 31)
+
+	alice := dialTable(t, server.URL, tableId, "Alice")
+	bob := dialTable(t, server.URL, tableId, "Bob")
+
+	// Each seat sees itself first, with its own hole cards and the other's masked
+	inHand := func(view clientStateView) bool {
+		return view.Round > 0 && view.Round < 5 && len(view.Players) == 2 && view.Players[0].Hand != "" && !strings.Contains(view.Players[0].Hand, "?")
+	}
+	aliceView := alice.state(t, tableId, inHand)
+	bobView := bob.state(t, tableId, inHand)
+
+	assert.Equal(t, "Alice", aliceView.Players[0].Name)
+	assert.Equal(t, "????", aliceView.Players[1].Hand)
+	assert.Equal(t, 0, aliceView.Viewing)
+	assert.Equal(t, "Bob", bobView.Players[0].Name)
+	assert.Equal(t, "????", bobView.Players[1].Hand)
+	assert.NotEqual(t, aliceView.Players[0].Hand, bobView.Players[0].Hand)
+
+	// Only the active seat gets valid moves; find who is to act
+	active, waiting, activeName := alice, bob, "Alice"
+	withTable(tableId, func(state *GameState) {
+		if strings.EqualFold(state.Players[state.ActivePlayer].Name, "Bob") {
+			active, waiting, activeName = bob, alice, "Bob"
+		}
+	})
+
+	// A move from the seat that is not to act is rejected
+	require.NoError(t, waiting.conn.WriteJSON(map[string]string{"move": "FO"}))
+	assert.Equal(t, "move FO not allowed", waiting.next(t, "error").Data)
+
+	// A frame that is not a move is rejected
+	require.NoError(t, active.conn.WriteMessage(websocket.TextMessage, []byte("hello")))
+	assert.Equal(t, `expected {"move":"XX"}`, active.next(t, "error").Data)
+
+	// The active seat folds over the socket and both seats are pushed the result
+	require.NoError(t, active.conn.WriteJSON(map[string]string{"move": "fo"}))
+	folded := func(view clientStateView) bool {
+		for _, p := range view.Players {
+			if p.Name == activeName && p.Status == int(STATUS_FOLDED) {
+				return true
+			}
+		}
+		return false
+	}
+	active.state(t, tableId, folded)
+	waiting.state(t, tableId, folded)
+}
+
+func TestWebSocketObserverAndUnknownTable(t *testing.T) {
+	useIntegrationTimers(t)
+	server, tableId := newHTTPTable(t, 2, 32)
+
+	// An observer gets the viewing state and is never seated
+	observer := dialTable(t, server.URL, tableId, "")
+	view := observer.state(t, tableId, func(view clientStateView) bool { return true })
+	assert.Equal(t, 1, view.Viewing)
+	withTable(tableId, func(state *GameState) {
+		assert.Len(t, state.Players, 2)
+	})
+
+	require.NoError(t, observer.conn.WriteJSON(map[string]string{"move": "CH"}))
+	assert.Equal(t, "move CH not allowed", observer.next(t, "error").Data)
+
+	// An unknown table is refused before the upgrade
+	u := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws?table=nosuchtable&player=Alice"
+	_, res, err := websocket.DefaultDialer.Dial(u, nil)
+	require.Error(t, err)
+	require.NotNil(t, res)
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+}